@@ -2,15 +2,19 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package battery implements reading of the standard 180f Bluetooth
-// battery service characteristic.
+// Package battery implements reading of and handling notifications
+// from the standard 180f Bluetooth battery service characteristic.
 package battery
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
 
 	"tinygo.org/x/bluetooth"
 
+	"github.com/kortschak/polar/gatt"
 	"github.com/kortschak/polar/internal/forkbeard"
 )
 
@@ -32,10 +36,10 @@ func must[T any](v T, err error) T {
 }
 
 // Level returns the battery level for the provided Bluetooth device.
-func Level(dev *bluetooth.Device) (int, error) {
+func Level(dev gatt.Device) (int, error) {
 	// https://www.bluetooth.com/specifications/specs/battery-service/
 
-	batteryDevice, err := forkbeard.DeviceCharacteristic(dev, batteryService, batteryLevelCharacteristic)
+	batteryDevice, err := dev.Characteristic(batteryService, batteryLevelCharacteristic)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get battery device characteristic: %w", err)
 	}
@@ -45,3 +49,78 @@ func Level(dev *bluetooth.Device) (int, error) {
 	}
 	return int(resp[0]), nil
 }
+
+// LevelListener implements handling of battery level notifications.
+type LevelListener struct {
+	char gatt.Characteristic
+}
+
+// NewLevelListener returns a new LevelListener for the provided Bluetooth
+// device. The h function is called with received battery level
+// notifications. Sensors typically only notify when the level changes.
+func NewLevelListener(dev gatt.Device, h func(int, error)) (*LevelListener, error) {
+	char, err := dev.Characteristic(batteryService, batteryLevelCharacteristic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get battery device characteristic: %w", err)
+	}
+	err = char.EnableNotifications(func(buf []byte) {
+		h(parseLevel(buf))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LevelListener{char: char}, nil
+}
+
+// Close disables battery level notifications from the connected sensor.
+func (l *LevelListener) Close() error { return l.char.EnableNotifications(nil) }
+
+func parseLevel(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, errors.New("empty battery level")
+	}
+	if buf[0] > 100 {
+		return int(buf[0]), fmt.Errorf("invalid battery level: %d", buf[0])
+	}
+	return int(buf[0]), nil
+}
+
+// Thresholds returns a battery level handler for use with
+// NewLevelListener that calls low when the battery level falls to or
+// below each of the provided thresholds, and then calls h if it is not
+// nil. The low function is called once for each threshold crossed, in
+// descending order of threshold, and is called again for a threshold
+// only after the level has risen above it, for example after charging.
+func Thresholds(h func(int, error), low func(level, threshold int), thresholds ...int) func(int, error) {
+	thresholds = slices.Clone(thresholds)
+	slices.Sort(thresholds)
+	slices.Reverse(thresholds)
+	thresholds = slices.Compact(thresholds)
+	armed := make([]bool, len(thresholds))
+	for i := range armed {
+		armed[i] = true
+	}
+	var mu sync.Mutex
+	return func(level int, err error) {
+		if err == nil {
+			mu.Lock()
+			var crossed []int
+			for i, t := range thresholds {
+				switch {
+				case level <= t && armed[i]:
+					armed[i] = false
+					crossed = append(crossed, t)
+				case level > t:
+					armed[i] = true
+				}
+			}
+			mu.Unlock()
+			for _, t := range crossed {
+				low(level, t)
+			}
+		}
+		if h != nil {
+			h(level, err)
+		}
+	}
+}
@@ -0,0 +1,679 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The polar command streams data from a Polar heart rate sensor.
+//
+// The command scans for a device with a name starting with the -name
+// flag value, connects to it and prints its device information,
+// supported PMD features and battery level to stderr. Battery level
+// changes and low battery warnings are logged as they are reported.
+// It then streams the measurements selected by the -streams flag to
+// stdout as CSV or JSON lines, one line per sample, until it is
+// interrupted or the -duration elapses.
+//
+// Each CSV line starts with the stream name and the sample time in
+// RFC 3339 format, followed by the stream's fields. JSON lines hold
+// the same data as objects with "stream" and "time" fields.
+//
+// Sample rates and ranges that are not specified by flags are chosen
+// from the first of the settings reported by the sensor. Specified
+// values are checked against the sensor's available settings. The -sdk
+// flag enables SDK mode, which makes wider sample rates and ranges
+// available on sensors that support it.
+//
+// If the connection to a sensor is lost, the command reconnects and
+// restarts the selected streams, logging the duration of the outage.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/battery"
+	"github.com/kortschak/polar/devinfo"
+	"github.com/kortschak/polar/discover"
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/heart"
+	"github.com/kortschak/polar/pmd"
+	"github.com/kortschak/polar/reconnect"
+	"github.com/kortschak/polar/sim"
+)
+
+func main() {
+	name := flag.String("name", "Polar", "device name prefix to connect to")
+	scan := flag.Duration("scan", 30*time.Second, "maximum scan duration")
+	streams := flag.String("streams", "hr", "comma-separated streams to record (hr, ecg, acc, ppg, ppi, gyro, mag)")
+	format := flag.String("format", "csv", "output format (csv or json)")
+	duration := flag.Duration("duration", 0, "recording duration (zero for unlimited)")
+	accRate := flag.Uint("acc-rate", 0, "accelerometer sample rate in Hz")
+	accRange := flag.Uint("acc-range", 0, "accelerometer range in G")
+	ppgRate := flag.Uint("ppg-rate", 0, "PPG sample rate in Hz")
+	gyroRate := flag.Uint("gyro-rate", 0, "gyroscope sample rate in Hz")
+	gyroRange := flag.Uint("gyro-range", 0, "gyroscope range in deg/s")
+	magRate := flag.Uint("mag-rate", 0, "magnetometer sample rate in Hz")
+	magRange := flag.Uint("mag-range", 0, "magnetometer range in G")
+	sdk := flag.Bool("sdk", false, "enable SDK mode for wider sample rates and ranges")
+	simulate := flag.String("sim", "", "use a simulated sensor (h10 or verity)")
+	flag.Parse()
+
+	var jsonLines bool
+	switch *format {
+	case "csv":
+	case "json":
+		jsonLines = true
+	default:
+		log.Fatalf("invalid format: %q", *format)
+	}
+	want := make(map[string]bool)
+	for _, s := range strings.Split(*streams, ",") {
+		s = strings.TrimSpace(s)
+		switch s {
+		case "hr", "ecg", "acc", "ppg", "ppi", "gyro", "mag":
+			want[s] = true
+		case "":
+		default:
+			log.Fatalf("invalid stream: %q", s)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	var (
+		dev gatt.Device
+		err error
+	)
+	if *simulate != "" {
+		dev, err = simulated(*simulate)
+	} else {
+		dev, err = supervise(ctx, *name, *scan)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := &emitter{w: os.Stdout, json: jsonLines, cancel: cancel}
+	err = run(ctx, dev, out, want, *sdk, rates{
+		acc:  [2]uint16{uint16(*accRate), uint16(*accRange)},
+		ppg:  [2]uint16{uint16(*ppgRate), 0},
+		gyro: [2]uint16{uint16(*gyroRate), uint16(*gyroRange)},
+		mag:  [2]uint16{uint16(*magRate), uint16(*magRange)},
+	})
+	if werr := out.Err(); werr != nil {
+		// The run was stopped by the write failure,
+		// so any error it returned is a consequence.
+		log.Fatalf("failed to write output: %v", werr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// simulated returns a simulated sensor of the named model.
+func simulated(model string) (gatt.Device, error) {
+	switch model {
+	case "h10":
+		return sim.New(sim.H10, 60)
+	case "verity":
+		return sim.New(sim.VeritySense, 60)
+	default:
+		return nil, fmt.Errorf("invalid simulated model: %q", model)
+	}
+}
+
+// supervise connects to a device with a local name starting with prefix
+// and reconnects to it when the connection is lost.
+func supervise(ctx context.Context, prefix string, timeout time.Duration) (gatt.Device, error) {
+	// Heart rate is notified continuously while a
+	// listener is registered, so its absence indicates
+	// a connection that has failed silently. PMD data
+	// is watched once the PMD listener is registered.
+	hrSrv, err := bluetooth.ParseUUID(heart.RateServiceID)
+	if err != nil {
+		return nil, err
+	}
+	hrChar, err := bluetooth.ParseUUID(heart.RateMeasurementID)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := reconnect.New(ctx, func(ctx context.Context) (gatt.Device, error) {
+		return connect(ctx, prefix, timeout)
+	}, reconnect.Config{
+		Report: func(o reconnect.Outage) {
+			log.Printf("reconnected after %v (%d attempts)", o.Restored.Sub(o.Lost), o.Attempts)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Watch(hrSrv, hrChar, nil)
+	bluetooth.DefaultAdapter.SetConnectHandler(func(_ bluetooth.Device, connected bool) {
+		if !connected {
+			s.Lost()
+		}
+	})
+	go s.Run(ctx)
+	return s, nil
+}
+
+// connect scans for a device with a local name starting with prefix
+// and connects to it.
+func connect(ctx context.Context, prefix string, timeout time.Duration) (gatt.Device, error) {
+	adapter := bluetooth.DefaultAdapter
+	err := adapter.Enable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable bluetooth adapter: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for c, err := range discover.Scan(ctx, adapter) {
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(c.Name, prefix) {
+			continue
+		}
+		log.Printf("found %s (model=%q id=%q address=%s rssi=%d)", c.Name, c.Model, c.ID, c.Address, c.RSSI)
+		return c.Connect(adapter)
+	}
+	return nil, fmt.Errorf("no device found with name prefix %q", prefix)
+}
+
+// rates holds the requested sample rate and range for PMD streams.
+type rates struct {
+	acc, ppg, gyro, mag [2]uint16
+}
+
+func run(ctx context.Context, dev gatt.Device, out *emitter, want map[string]bool, sdk bool, r rates) error {
+	defer dev.Disconnect()
+
+	info, err := devinfo.Read(dev)
+	if err != nil {
+		log.Printf("failed to read device information: %v", err)
+	} else {
+		log.Printf("device: %s %s serial=%s hardware=%s firmware=%s", info.Manufacturer, info.Model, info.Serial, info.Hardware, info.Firmware)
+	}
+
+	level, err := battery.Level(dev)
+	if err != nil {
+		log.Printf("failed to read battery level: %v", err)
+	} else {
+		log.Printf("battery: %d%%", level)
+	}
+	bl, err := battery.NewLevelListener(dev, battery.Thresholds(
+		func(level int, err error) {
+			if err != nil {
+				log.Printf("battery: %v", err)
+				return
+			}
+			log.Printf("battery: %d%%", level)
+		},
+		func(level, threshold int) {
+			log.Printf("battery low: %d%% (threshold %d%%)", level, threshold)
+		},
+		20, 10, 5,
+	))
+	if err != nil {
+		log.Printf("failed to subscribe to battery level: %v", err)
+	} else {
+		defer bl.Close()
+	}
+
+	if want["hr"] {
+		l, err := heart.NewRateListener(dev, func(m heart.Rate, err error) {
+			now := time.Now()
+			if err != nil {
+				log.Printf("hr: %v", err)
+				return
+			}
+			rr := make([]float64, len(m.RR))
+			for i, d := range m.RR {
+				rr[i] = float64(d) / float64(time.Millisecond)
+			}
+			out.emit("hr", now,
+				field{"bpm", m.HR},
+				field{"rr_ms", rr},
+				field{"contact", m.Contact},
+			)
+		})
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+	}
+
+	var pmdStreams []string
+	for _, s := range []string{"ecg", "acc", "ppg", "ppi", "gyro", "mag"} {
+		if want[s] {
+			pmdStreams = append(pmdStreams, s)
+		}
+	}
+	if len(pmdStreams) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	l, err := pmd.NewListener(dev)
+	if err != nil {
+		return err
+	}
+	log.Printf("features: %v", l.Features())
+	if s, ok := dev.(*reconnect.Supervisor); ok {
+		s.Register(l)
+		srv, err := bluetooth.ParseUUID(pmd.ServiceID)
+		if err != nil {
+			return err
+		}
+		char, err := bluetooth.ParseUUID(pmd.DataID)
+		if err != nil {
+			return err
+		}
+		s.Watch(srv, char, l.Streaming)
+	}
+	if sdk {
+		err = l.SetSDKMode(ctx, true)
+		if err != nil {
+			return fmt.Errorf("failed to enable sdk mode: %w", err)
+		}
+		defer func() {
+			// Deferred before the streams are
+			// started so that this runs after
+			// they have been stopped.
+			stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := l.SetSDKMode(stopCtx, false)
+			if err != nil {
+				log.Printf("failed to disable sdk mode: %v", err)
+			}
+		}()
+	}
+
+	var started []pmd.Handler
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, h := range started {
+			_, err := l.SetHandler(stopCtx, stop(h))
+			if err != nil {
+				log.Printf("failed to stop stream: %v", err)
+			}
+		}
+	}()
+	for _, s := range pmdStreams {
+		h, err := handler(ctx, l, s, out, r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s, err)
+		}
+		started = append(started, h)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// handler starts the named PMD stream and returns its handler.
+func handler(ctx context.Context, l *pmd.Listener, stream string, out *emitter, r rates) (pmd.Handler, error) {
+	var (
+		h pmd.Handler
+		// held holds notifications for streams that
+		// need the conversion factor returned by the
+		// sensor when the stream is started.
+		held factored
+	)
+	switch stream {
+	case "ecg":
+		h = pmd.ECGHandler(func(buf []byte) {
+			var m pmd.ECG
+			if err := m.UnmarshalBinary(buf); err != nil {
+				log.Printf("ecg: %v", err)
+				return
+			}
+			for t, v := range m.Samples() {
+				out.emit("ecg", t, field{"uV", v})
+			}
+		})
+
+	case "acc":
+		rate, rng, err := choose(ctx, l, pmd.AccType, r.acc)
+		if err != nil {
+			return nil, err
+		}
+		held.handle = func(buf []byte, factor float32) {
+			m := pmd.AccFrame{
+				SampleFreq: pmd.AccSampleFreq(rate),
+				Factor:     factor,
+			}
+			if err := m.UnmarshalBinary(buf); err != nil {
+				log.Printf("acc: %v", err)
+				return
+			}
+			for _, s := range m.Samples {
+				out.emit("acc", s.Timestamp, field{"x_mG", s.X}, field{"y_mG", s.Y}, field{"z_mG", s.Z})
+			}
+		}
+		h = pmd.AccHandler{
+			SampleFreq: pmd.AccSampleFreq(rate),
+			Range:      pmd.AccRange(rng),
+			Handler:    held.notify,
+		}
+
+	case "ppg":
+		rate, _, err := choose(ctx, l, pmd.PPGType, r.ppg)
+		if err != nil {
+			return nil, err
+		}
+		h = pmd.PPGHandler{
+			SampleFreq: pmd.PPGSampleFreq(rate),
+			Handler: func(buf []byte) {
+				m := pmd.PPGFrame{SampleFreq: pmd.PPGSampleFreq(rate)}
+				if err := m.UnmarshalBinary(buf); err != nil {
+					log.Printf("ppg: %v", err)
+					return
+				}
+				for _, s := range m.Samples {
+					out.emit("ppg", s.Timestamp, field{"channels", s.Channels}, field{"ambient", s.Ambient}, field{"status", s.Status})
+				}
+			},
+		}
+
+	case "ppi":
+		h = pmd.PPIHandler(func(buf []byte) {
+			now := time.Now()
+			var m pmd.PPIFrame
+			if err := m.UnmarshalBinary(buf); err != nil {
+				log.Printf("ppi: %v", err)
+				return
+			}
+			for _, s := range m.Samples {
+				out.emit("ppi", now,
+					field{"bpm", s.HR},
+					field{"pp_ms", s.Interval.Milliseconds()},
+					field{"error_ms", s.Error.Milliseconds()},
+					field{"blocker", s.Blocker},
+					field{"contact", s.Contact},
+				)
+			}
+		})
+
+	case "gyro":
+		rate, rng, err := choose(ctx, l, pmd.GyroType, r.gyro)
+		if err != nil {
+			return nil, err
+		}
+		held.handle = func(buf []byte, factor float32) {
+			m := pmd.GyroFrame{
+				SampleFreq: pmd.GyroSampleFreq(rate),
+				Factor:     factor,
+			}
+			if err := m.UnmarshalBinary(buf); err != nil {
+				log.Printf("gyro: %v", err)
+				return
+			}
+			for _, s := range m.Samples {
+				out.emit("gyro", s.Timestamp, field{"x_dps", s.X}, field{"y_dps", s.Y}, field{"z_dps", s.Z})
+			}
+		}
+		h = pmd.GyroHandler{
+			SampleFreq: pmd.GyroSampleFreq(rate),
+			Range:      pmd.GyroRange(rng),
+			Handler:    held.notify,
+		}
+
+	case "mag":
+		rate, rng, err := choose(ctx, l, pmd.MagnetometerType, r.mag)
+		if err != nil {
+			return nil, err
+		}
+		held.handle = func(buf []byte, factor float32) {
+			m := pmd.MagFrame{
+				SampleFreq: pmd.MagSampleFreq(rate),
+				Factor:     factor,
+			}
+			if err := m.UnmarshalBinary(buf); err != nil {
+				log.Printf("mag: %v", err)
+				return
+			}
+			for _, s := range m.Samples {
+				out.emit("mag", s.Timestamp, field{"x_G", s.X}, field{"y_G", s.Y}, field{"z_G", s.Z}, field{"calibration", s.Calibration})
+			}
+		}
+		h = pmd.MagHandler{
+			SampleFreq: pmd.MagSampleFreq(rate),
+			Range:      pmd.MagRange(rng),
+			Handler:    held.notify,
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid stream: %q", stream)
+	}
+
+	resp, err := l.SetHandler(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := resp.Settings()
+	if err != nil {
+		log.Printf("%s: invalid start response settings: %v", stream, err)
+	}
+	held.setFactor(pmd.ConversionFactor(settings))
+	return h, nil
+}
+
+// factored holds the notifications of a stream that is decoded with the
+// conversion factor returned by the sensor when the stream is started
+// until the factor is known, so that early notifications are not
+// decoded with the wrong factor.
+type factored struct {
+	handle func(buf []byte, factor float32)
+
+	mu      sync.Mutex
+	factor  float32
+	known   bool
+	pending [][]byte
+}
+
+// notify handles the notification in buf if the conversion factor is
+// known and holds it otherwise.
+func (f *factored) notify(buf []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.known {
+		f.pending = append(f.pending, bytes.Clone(buf))
+		return
+	}
+	f.handle(buf, f.factor)
+}
+
+// setFactor sets the conversion factor and handles any held
+// notifications.
+func (f *factored) setFactor(factor float32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.factor = factor
+	f.known = true
+	for _, buf := range f.pending {
+		f.handle(buf, factor)
+	}
+	f.pending = nil
+}
+
+// choose returns the sample rate and range to use for the measurement
+// type based on the requested values in req and the sensor's available
+// settings. Zero values in req are replaced with the first available
+// setting.
+func choose(ctx context.Context, l *pmd.Listener, m pmd.MeasureType, req [2]uint16) (rate, rng uint16, err error) {
+	settings, err := l.Settings(ctx, m)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get settings: %w", err)
+	}
+	rate, err = setting(settings, pmd.SampleRateSetting, req[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("sample rate: %w", err)
+	}
+	rng, err = setting(settings, pmd.RangeUnitSetting, req[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("range: %w", err)
+	}
+	return rate, rng, nil
+}
+
+// setting returns the requested value of the setting type if it is
+// available, or the first available value if req is zero. If the
+// setting type is not available, zero is returned.
+func setting(settings []pmd.Setting, typ pmd.SettingType, req uint16) (uint16, error) {
+	for _, s := range settings {
+		s, ok := s.(pmd.Uint16)
+		if !ok || s.Type != typ || len(s.Val) == 0 {
+			continue
+		}
+		if req == 0 {
+			return s.Val[0], nil
+		}
+		if !slices.Contains(s.Val, req) {
+			return 0, fmt.Errorf("%d not available: %v", req, s.Val)
+		}
+		return req, nil
+	}
+	if req != 0 {
+		return 0, errors.New("not available")
+	}
+	return 0, nil
+}
+
+// stop returns a handler that stops the stream started by h.
+func stop(h pmd.Handler) pmd.Handler {
+	switch h := h.(type) {
+	case pmd.ECGHandler:
+		return pmd.ECGHandler(nil)
+	case pmd.AccHandler:
+		h.Handler = nil
+		return h
+	case pmd.PPGHandler:
+		h.Handler = nil
+		return h
+	case pmd.PPIHandler:
+		return pmd.PPIHandler(nil)
+	case pmd.GyroHandler:
+		h.Handler = nil
+		return h
+	case pmd.MagHandler:
+		h.Handler = nil
+		return h
+	default:
+		panic(fmt.Sprintf("unknown handler type: %T", h))
+	}
+}
+
+// emitter writes samples as CSV or JSON lines. Since samples are
+// emitted from notification goroutines, a write failure is recorded and
+// cancel is called so that the run shuts down cleanly; the error is
+// then available from Err.
+type emitter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	json   bool
+	buf    []byte
+	cancel context.CancelFunc
+	err    error
+}
+
+// field is a named sample value.
+type field struct {
+	name string
+	val  any
+}
+
+func (e *emitter) emit(stream string, t time.Time, fields ...field) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return
+	}
+	buf := e.buf[:0]
+	if e.json {
+		buf = append(buf, `{"stream":`...)
+		buf = strconv.AppendQuote(buf, stream)
+		buf = append(buf, `,"time":`...)
+		buf = strconv.AppendQuote(buf, t.Format(time.RFC3339Nano))
+		for _, f := range fields {
+			buf = append(buf, ',')
+			buf = strconv.AppendQuote(buf, f.name)
+			buf = append(buf, ':')
+			b, err := json.Marshal(f.val)
+			if err != nil {
+				b = []byte("null")
+			}
+			buf = append(buf, b...)
+		}
+		buf = append(buf, "}\n"...)
+	} else {
+		buf = append(buf, stream...)
+		buf = append(buf, ',')
+		buf = t.AppendFormat(buf, time.RFC3339Nano)
+		for _, f := range fields {
+			buf = append(buf, ',')
+			buf = appendCSV(buf, f.val)
+		}
+		buf = append(buf, '\n')
+	}
+	e.buf = buf
+	_, err := e.w.Write(buf)
+	if err != nil {
+		e.err = err
+		e.cancel()
+	}
+}
+
+// Err returns the first error encountered writing samples.
+func (e *emitter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// appendCSV appends the CSV representation of v to dst. Slice values
+// are separated by semicolons.
+func appendCSV(dst []byte, v any) []byte {
+	switch v := v.(type) {
+	case []int32:
+		for i, e := range v {
+			if i != 0 {
+				dst = append(dst, ';')
+			}
+			dst = strconv.AppendInt(dst, int64(e), 10)
+		}
+		return dst
+	case []float64:
+		for i, e := range v {
+			if i != 0 {
+				dst = append(dst, ';')
+			}
+			dst = strconv.AppendFloat(dst, e, 'g', -1, 64)
+		}
+		return dst
+	case float32:
+		return strconv.AppendFloat(dst, float64(v), 'g', -1, 32)
+	default:
+		return fmt.Append(dst, v)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package devinfo implements reading of the standard 180a Bluetooth
+// device information service characteristics.
+package devinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/internal/forkbeard"
+)
+
+const (
+	ServiceID = "180a"
+
+	SystemIDCharacteristicID         = "2a23"
+	ModelNumberCharacteristicID      = "2a24"
+	SerialNumberCharacteristicID     = "2a25"
+	FirmwareRevisionCharacteristicID = "2a26"
+	HardwareRevisionCharacteristicID = "2a27"
+	SoftwareRevisionCharacteristicID = "2a28"
+	ManufacturerNameCharacteristicID = "2a29"
+)
+
+var (
+	infoService = must(bluetooth.ParseUUID(ServiceID))
+
+	systemID         = must(bluetooth.ParseUUID(SystemIDCharacteristicID))
+	modelNumber      = must(bluetooth.ParseUUID(ModelNumberCharacteristicID))
+	serialNumber     = must(bluetooth.ParseUUID(SerialNumberCharacteristicID))
+	firmwareRevision = must(bluetooth.ParseUUID(FirmwareRevisionCharacteristicID))
+	hardwareRevision = must(bluetooth.ParseUUID(HardwareRevisionCharacteristicID))
+	softwareRevision = must(bluetooth.ParseUUID(SoftwareRevisionCharacteristicID))
+	manufacturerName = must(bluetooth.ParseUUID(ManufacturerNameCharacteristicID))
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Info is the device information reported by a sensor. Fields for
+// characteristics that the sensor does not provide are left empty.
+type Info struct {
+	Manufacturer string
+	Model        string
+	Serial       string
+	Hardware     string
+	Firmware     string
+	Software     string
+	SystemID     SystemID
+}
+
+// SystemID is a device system ID.
+type SystemID [8]byte
+
+// Manufacturer returns the manufacturer-defined identifier held in the
+// system ID.
+func (id SystemID) Manufacturer() uint64 {
+	return binary.LittleEndian.Uint64(id[:]) & (1<<40 - 1)
+}
+
+// OUI returns the organizationally unique identifier held in the
+// system ID.
+func (id SystemID) OUI() uint32 {
+	return uint32(binary.LittleEndian.Uint64(id[:]) >> 40)
+}
+
+func (id SystemID) String() string {
+	return fmt.Sprintf("%x", [8]byte(id))
+}
+
+// Read returns the device information for the provided Bluetooth
+// device. Characteristics that are not found on the device are
+// skipped. It is an error for none of the characteristics to be found.
+func Read(dev gatt.Device) (Info, error) {
+	// https://www.bluetooth.com/specifications/specs/device-information-service-1-1/
+
+	var (
+		info  Info
+		found bool
+	)
+	for _, f := range []struct {
+		id  bluetooth.UUID
+		dst *string
+	}{
+		{id: manufacturerName, dst: &info.Manufacturer},
+		{id: modelNumber, dst: &info.Model},
+		{id: serialNumber, dst: &info.Serial},
+		{id: hardwareRevision, dst: &info.Hardware},
+		{id: firmwareRevision, dst: &info.Firmware},
+		{id: softwareRevision, dst: &info.Software},
+	} {
+		resp, ok, err := read(dev, f.id)
+		if err != nil {
+			return info, err
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		// Some devices pad strings with NUL bytes.
+		*f.dst = string(bytes.TrimRight(resp, "\x00"))
+	}
+	resp, ok, err := read(dev, systemID)
+	if err != nil {
+		return info, err
+	}
+	if ok {
+		found = true
+		if len(resp) != len(info.SystemID) {
+			return info, fmt.Errorf("invalid system id length: %#x", resp)
+		}
+		copy(info.SystemID[:], resp)
+	}
+	if !found {
+		return info, fmt.Errorf("no device information characteristics found")
+	}
+	return info, nil
+}
+
+// read returns the value of the device information characteristic
+// with the provided id and whether the characteristic was found.
+func read(dev gatt.Device, id bluetooth.UUID) ([]byte, bool, error) {
+	char, err := dev.Characteristic(infoService, id)
+	if err != nil {
+		if errors.Is(err, gatt.ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get device information characteristic %s: %w", id, err)
+	}
+	resp, err := forkbeard.ReadCharacteristic(char)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed read device information characteristic %s: %w", id, err)
+	}
+	return resp, true, nil
+}
@@ -0,0 +1,162 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package discover implements scanning for Polar sensors.
+package discover
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/heart"
+	"github.com/kortschak/polar/pmd"
+)
+
+var (
+	hrService  = must(bluetooth.ParseUUID(heart.RateServiceID))
+	pmdService = must(bluetooth.ParseUUID(pmd.ServiceID))
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Candidate is a discovered sensor.
+type Candidate struct {
+	Address bluetooth.Address
+	RSSI    int16
+
+	// Name is the advertised local name of the
+	// sensor, for example "Polar H10 A1B2C3D4".
+	Name string
+	// Model and ID are the model and device ID
+	// parsed from Name. They are empty if Name
+	// is not a Polar device name.
+	Model string
+	ID    string
+
+	// HeartRate and PMD indicate whether the sensor
+	// advertises the heart rate and PMD services.
+	HeartRate bool
+	PMD       bool
+}
+
+// Connect connects to the candidate sensor using the provided adapter.
+// Any scan in progress on the adapter is stopped before connecting,
+// since adapters may fail to connect while scanning. When called during
+// an iteration of Scan, this ends the iteration after the current
+// candidate.
+func (c Candidate) Connect(adapter *bluetooth.Adapter) (gatt.Device, error) {
+	// StopScan fails if the adapter is not
+	// scanning, which is not an error here.
+	adapter.StopScan()
+	dev, err := adapter.Connect(c.Address, bluetooth.ConnectionParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.Address, err)
+	}
+	return gatt.NewDevice(&dev), nil
+}
+
+// ParseName parses a Polar sensor name of the form "Polar <model> <id>"
+// where id is the device ID printed on the sensor. The model may hold
+// more than one word.
+func ParseName(name string) (model, id string, ok bool) {
+	f := strings.Fields(name)
+	if len(f) < 3 || f[0] != "Polar" || !isID(f[len(f)-1]) {
+		return "", "", false
+	}
+	return strings.Join(f[1:len(f)-1], " "), f[len(f)-1], true
+}
+
+// isID returns whether s is a Polar device ID.
+func isID(s string) bool {
+	if len(s) != 8 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789ABCDEFabcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Scan scans for sensors advertising the heart rate or PMD services
+// using the provided adapter, which must be enabled. Each sensor is
+// yielded when it is first seen and, if its name was not known then,
+// again when its name is first seen, since the name may only be sent
+// in a scan response. Scanning stops when ctx is
+// done, when the iteration is stopped, or when a candidate is connected
+// to with Candidate.Connect. If scanning fails, the error is yielded.
+func Scan(ctx context.Context, adapter *bluetooth.Adapter) iter.Seq2[Candidate, error] {
+	return func(yield func(Candidate, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		found := make(chan Candidate)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- adapter.Scan(func(a *bluetooth.Adapter, r bluetooth.ScanResult) {
+				c := Candidate{
+					Address:   r.Address,
+					RSSI:      r.RSSI,
+					Name:      r.LocalName(),
+					HeartRate: r.HasServiceUUID(hrService),
+					PMD:       r.HasServiceUUID(pmdService),
+				}
+				if !c.HeartRate && !c.PMD && c.Name == "" {
+					return
+				}
+				c.Model, c.ID, _ = ParseName(c.Name)
+				select {
+				case found <- c:
+				case <-ctx.Done():
+				}
+			})
+		}()
+		go func() {
+			<-ctx.Done()
+			adapter.StopScan()
+		}()
+
+		// seen holds the last yielded candidate
+		// for each address.
+		seen := make(map[bluetooth.Address]Candidate)
+		for {
+			select {
+			case c := <-found:
+				prev, ok := seen[c.Address]
+				if !c.HeartRate && !c.PMD {
+					// Scan responses may hold the
+					// name without the services of
+					// the advertisement.
+					if !ok {
+						continue
+					}
+					c.HeartRate, c.PMD = prev.HeartRate, prev.PMD
+				}
+				if ok && (prev.Name != "" || c.Name == "") {
+					continue
+				}
+				seen[c.Address] = c
+				if !yield(c, nil) {
+					return
+				}
+			case err := <-errc:
+				if err != nil && ctx.Err() == nil {
+					yield(Candidate{}, fmt.Errorf("failed to scan: %w", err))
+				}
+				return
+			}
+		}
+	}
+}
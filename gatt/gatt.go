@@ -0,0 +1,119 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gatt defines the Bluetooth GATT client transport used by the
+// polar packages.
+//
+// The Device and Characteristic interfaces are implemented for
+// [tinygo.org/x/bluetooth] devices by the value returned by NewDevice,
+// and may be implemented by other transports or by fakes to drive the
+// polar packages without sensor hardware.
+package gatt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Device is a connected Bluetooth peripheral.
+type Device interface {
+	// Characteristic discovers and returns the specified
+	// characteristic of the specified service. If the
+	// device does not have the service or characteristic,
+	// the returned error wraps ErrNotFound.
+	Characteristic(service, char bluetooth.UUID) (Characteristic, error)
+
+	// Disconnect disconnects from the peripheral.
+	Disconnect() error
+}
+
+// ErrNotFound is returned by Device.Characteristic when the requested
+// service or characteristic is not present on the device.
+var ErrNotFound = errors.New("device characteristic not found")
+
+// Characteristic is a GATT characteristic of a connected peripheral.
+type Characteristic interface {
+	// Read reads the value of the characteristic into
+	// data and returns the number of bytes read.
+	Read(data []byte) (int, error)
+
+	// WriteWithoutResponse writes p to the characteristic
+	// without waiting for acknowledgement.
+	WriteWithoutResponse(p []byte) (int, error)
+
+	// EnableNotifications registers callback to be called
+	// with the data of each notification or indication
+	// from the characteristic. A nil callback disables
+	// notifications.
+	EnableNotifications(callback func(buf []byte)) error
+
+	// GetMTU returns the MTU of the characteristic.
+	GetMTU() (uint16, error)
+}
+
+// NewDevice returns a Device backed by the provided Bluetooth device.
+// Discovered services and characteristics are cached for the lifetime
+// of the returned Device.
+func NewDevice(dev *bluetooth.Device) Device {
+	return &device{dev: dev}
+}
+
+type device struct {
+	dev *bluetooth.Device
+
+	// mu protects the discovery cache. services
+	// is only valid when discovered is true, and
+	// chars holds the characteristics of each
+	// service that has been searched.
+	mu         sync.Mutex
+	discovered bool
+	services   []bluetooth.DeviceService
+	chars      map[bluetooth.UUID][]bluetooth.DeviceCharacteristic
+}
+
+func (d *device) Characteristic(srvID, charID bluetooth.UUID) (Characteristic, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Discovery is not filtered by UUID since the
+	// bluetooth package reports missing services and
+	// characteristics with errors that cannot be told
+	// apart from discovery failures.
+	if !d.discovered {
+		srv, err := d.dev.DiscoverServices(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover service %s: %w", srvID, err)
+		}
+		d.services = srv
+		d.chars = make(map[bluetooth.UUID][]bluetooth.DeviceCharacteristic)
+		d.discovered = true
+	}
+	for _, s := range d.services {
+		if s.UUID() != srvID {
+			continue
+		}
+		chars, ok := d.chars[srvID]
+		if !ok {
+			var err error
+			chars, err = s.DiscoverCharacteristics(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to discover characteristic %s: %w", charID, err)
+			}
+			d.chars[srvID] = chars
+		}
+		for i := range chars {
+			if chars[i].UUID() == charID {
+				return &chars[i], nil
+			}
+		}
+		break
+	}
+	return nil, ErrNotFound
+}
+
+func (d *device) Disconnect() error {
+	return d.dev.Disconnect()
+}
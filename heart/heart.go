@@ -14,7 +14,7 @@ import (
 
 	"tinygo.org/x/bluetooth"
 
-	"github.com/kortschak/polar/internal/forkbeard"
+	"github.com/kortschak/polar/gatt"
 )
 
 const (
@@ -36,13 +36,13 @@ func must[T any](v T, err error) T {
 
 // RateListener implements handling of heart rate notifications.
 type RateListener struct {
-	char bluetooth.DeviceCharacteristic
+	char gatt.Characteristic
 }
 
 // NewRateListener returns a new RateListener for the provided Bluetooth
 // device. The h function is called with received heart rate notifications.
-func NewRateListener(dev *bluetooth.Device, h func(Rate, error)) (*RateListener, error) {
-	char, err := forkbeard.DeviceCharacteristic(dev, hrService, hrMeasurement)
+func NewRateListener(dev gatt.Device, h func(Rate, error)) (*RateListener, error) {
+	char, err := dev.Characteristic(hrService, hrMeasurement)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get heart rate device characteristic: %w", err)
 	}
@@ -10,31 +10,11 @@ import (
 	"fmt"
 	"io"
 
-	"tinygo.org/x/bluetooth"
+	"github.com/kortschak/polar/gatt"
 )
 
-// DeviceCharacteristic returns a specified bluetooth.DeviceCharacteristic
-// from a Bluetooth service.
-func DeviceCharacteristic(dev *bluetooth.Device, srvID, charID bluetooth.UUID) (bluetooth.DeviceCharacteristic, error) {
-	srv, err := dev.DiscoverServices([]bluetooth.UUID{srvID})
-	if err != nil {
-		return bluetooth.DeviceCharacteristic{}, fmt.Errorf("failed to discover service %s: %w", srvID, err)
-	}
-	for _, s := range srv {
-		char, err := s.DiscoverCharacteristics([]bluetooth.UUID{charID})
-		if err != nil {
-			return bluetooth.DeviceCharacteristic{}, fmt.Errorf("failed to discover characteristic %s: %w", charID, err)
-		}
-		if len(char) == 0 {
-			break
-		}
-		return char[0], nil
-	}
-	return bluetooth.DeviceCharacteristic{}, fmt.Errorf("device characteristic not found")
-}
-
 // ReadCharacteristic reads data from a Bluetooth characteristic.
-func ReadCharacteristic(char bluetooth.DeviceCharacteristic) ([]byte, error) {
+func ReadCharacteristic(char gatt.Characteristic) ([]byte, error) {
 	mtu, err := char.GetMTU()
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain mtu of characteristic: %w", err)
@@ -7,6 +7,8 @@ package pmd
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 	"time"
 )
 
@@ -23,6 +25,10 @@ const (
 	AccRange2G AccRange = 2 // G
 	AccRange4G AccRange = 4 // G
 	AccRange8G AccRange = 8 // G
+
+	// accCompressedResolution is the sample resolution
+	// of delta-compressed acceleration frames.
+	accCompressedResolution = 16 // bits
 )
 
 type AccSampleFreq uint16
@@ -36,7 +42,8 @@ type AccHandler struct {
 	// Range is the acceleration resolution to use.
 	Range AccRange
 
-	// Handler is called for each notification.
+	// Handler is called for each notification. The
+	// notification data can be decoded with AccFrame.
 	Handler func([]byte)
 }
 
@@ -54,29 +61,19 @@ func (h AccHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
 // Acc is an acceleration measurement.
 type Acc struct {
 	Timestamp time.Time
-	X, Y, Z   int32
+	X, Y, Z   int32 // mG
 }
 
+// UnmarshalBinary decodes the first acceleration sample in a PMD
+// notification. The sample is not scaled by the stream's conversion
+// factor. AccFrame should be used to decode all the samples.
 func (m *Acc) UnmarshalBinary(data []byte) error {
-	if MeasureType(data[sampleTypeOffset]) != AccType {
-		return fmt.Errorf("expected sample type acc: %v", data[sampleTypeOffset])
+	samples, err := accSamples(data)
+	if err != nil {
+		return err
 	}
-	var x, y, z int32
-	switch FrameType(data[frameTypeOffset]) {
-	case AccFrameType0:
-		x = int32(int8(data[dataOffset]))
-		y = int32(int8(data[dataOffset+1]))
-		z = int32(int8(data[dataOffset+2]))
-	case AccFrameType1:
-		x = int32(int16(binary.LittleEndian.Uint16(data[dataOffset:])))
-		y = int32(int16(binary.LittleEndian.Uint16(data[dataOffset+uint16Size:])))
-		z = int32(int16(binary.LittleEndian.Uint16(data[dataOffset+2*uint16Size:])))
-	case AccFrameType2:
-		x = leInt24(data[dataOffset:])
-		y = leInt24(data[dataOffset+int24Size:])
-		z = leInt24(data[dataOffset+2*int24Size:])
-	default:
-		return fmt.Errorf("expected frame type acc0/acc1/acc2: %v", data[frameTypeOffset])
+	if len(samples) == 0 {
+		return fmt.Errorf("no acc samples")
 	}
 
 	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
@@ -84,7 +81,115 @@ func (m *Acc) UnmarshalBinary(data []byte) error {
 	*m = Acc{
 		Timestamp: time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9),
 
-		X: x, Y: y, Z: z,
+		X: samples[0], Y: samples[1], Z: samples[2],
+	}
+	return nil
+}
+
+// AccFrame is the set of acceleration measurements held in a single
+// PMD notification.
+type AccFrame struct {
+	// SampleFreq is the sample frequency of the stream.
+	// It must be set before calling UnmarshalBinary and
+	// is used to calculate the timestamp of each sample.
+	SampleFreq AccSampleFreq
+	// Factor is the conversion factor from integer
+	// samples to mG returned by the sensor when the
+	// stream was started, as is done by sensors that
+	// send compressed acc1 frames such as the Verity
+	// Sense. It is obtained by calling ConversionFactor
+	// with the settings of the response returned by
+	// Listener.SetHandler. If Factor is zero, a factor
+	// of 1 is used.
+	Factor float32
+
+	// Timestamp is the time of the last sample.
+	Timestamp time.Time
+	Samples   []Acc
+}
+
+// UnmarshalBinary decodes all the acceleration samples in a PMD
+// notification. The PMD timestamp refers to the last sample in the
+// notification, so the timestamps of earlier samples are calculated
+// from m.SampleFreq.
+func (m *AccFrame) UnmarshalBinary(data []byte) error {
+	if m.SampleFreq == 0 {
+		return fmt.Errorf("acc sample frequency not set")
+	}
+	samples, err := accSamples(data)
+	if err != nil {
+		return err
+	}
+	if m.Factor != 0 && m.Factor != 1 {
+		for i, v := range samples {
+			samples[i] = int32(math.Round(float64(float32(v) * m.Factor)))
+		}
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+	last := time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9)
+
+	interval := time.Second / time.Duration(m.SampleFreq)
+	n := len(samples) / 3
+	accSamples := make([]Acc, n)
+	for i := range accSamples {
+		accSamples[i] = Acc{
+			Timestamp: sampleTime(last, i, n, interval),
+
+			X: samples[3*i], Y: samples[3*i+1], Z: samples[3*i+2],
+		}
+	}
+
+	*m = AccFrame{
+		SampleFreq: m.SampleFreq,
+		Factor:     m.Factor,
+		Timestamp:  last,
+		Samples:    accSamples,
 	}
 	return nil
 }
+
+// accSamples returns the X, Y, Z interleaved acceleration samples held
+// in the PMD notification in data.
+func accSamples(data []byte) ([]int32, error) {
+	if len(data) < dataOffset {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != AccType {
+		return nil, fmt.Errorf("expected sample type acc: %v", data[sampleTypeOffset])
+	}
+	frameType, comp := isCompressed(data[frameTypeOffset])
+	if comp {
+		switch frameType {
+		case AccFrameType0, AccFrameType1:
+		default:
+			return nil, fmt.Errorf("expected compressed frame type acc0/acc1: %v", data[frameTypeOffset])
+		}
+		samples, err := deltaFrames(data[dataOffset:], 3, accCompressedResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode compressed acc frame: %w", err)
+		}
+		return samples, nil
+	}
+
+	var size int
+	switch frameType {
+	case AccFrameType0:
+		size = uint8Size
+	case AccFrameType1:
+		size = uint16Size
+	case AccFrameType2:
+		size = int24Size
+	default:
+		return nil, fmt.Errorf("expected frame type acc0/acc1/acc2: %v", data[frameTypeOffset])
+	}
+	raw := data[dataOffset:]
+	if len(raw)%(3*size) != 0 {
+		return nil, fmt.Errorf("number of samples not a factor of %d: %v", 3*size, len(raw)%(3*size))
+	}
+	samples := make([]int32, len(raw)/size)
+	for i := range samples {
+		samples[i] = leIntN(raw[i*size : (i+1)*size])
+	}
+	return samples, nil
+}
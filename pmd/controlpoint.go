@@ -0,0 +1,155 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/kortschak/polar/gatt"
+)
+
+// controlPoint serialises transactions on a PMD control point
+// characteristic and routes control point notifications to the
+// pending transaction.
+type controlPoint struct {
+	char gatt.Characteristic
+
+	// mu serialises transactions.
+	mu sync.Mutex
+
+	// pmu protects pending.
+	pmu     sync.Mutex
+	pending *transaction
+}
+
+// anyMeasure is the measurement type of transactions for commands
+// that do not apply to a single measurement type. It matches the
+// measurement type of any response.
+const anyMeasure MeasureType = 0xff
+
+// transaction is a control point command awaiting its response.
+type transaction struct {
+	command Command
+	measure MeasureType
+	resp    chan []byte
+
+	// partial holds the response being reassembled
+	// from a multi-part notification sequence. It
+	// is protected by the controlPoint's pmu.
+	partial []byte
+}
+
+// newControlPoint returns a controlPoint for the provided control point
+// characteristic, registering for its notifications.
+func newControlPoint(char gatt.Characteristic) (*controlPoint, error) {
+	cp := &controlPoint{char: char}
+	err := char.EnableNotifications(cp.notify)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// notify delivers a control point notification to the pending
+// transaction if it is the response to the transaction's command.
+// Other notifications, including late responses to abandoned
+// transactions, are dropped.
+//
+// Responses with parameters that do not fit in a single notification
+// have the "more" flag set and are followed by continuation
+// notifications holding a "more" flag and further parameters. These
+// are reassembled into a single response with the flag cleared before
+// delivery.
+func (cp *controlPoint) notify(buf []byte) {
+	cp.pmu.Lock()
+	defer cp.pmu.Unlock()
+	t := cp.pending
+	if t == nil {
+		return
+	}
+	if t.partial == nil {
+		if !t.matches(buf) {
+			return
+		}
+		// | 0    | 1  | 2    | 3      | 4    | 5...   |
+		// | 0xf0 | op | type | status | more | params |
+		//
+		// The more flag is only present for
+		// successful commands.
+		if len(buf) < 5 || Status(buf[3]) != Success || buf[4] == 0 {
+			t.deliver(bytes.Clone(buf))
+			return
+		}
+		t.partial = bytes.Clone(buf)
+		return
+	}
+	// | 0    | 1...   |
+	// | more | params |
+	if len(buf) == 0 {
+		return
+	}
+	t.partial = append(t.partial, buf[1:]...)
+	if buf[0] != 0 {
+		return
+	}
+	resp := t.partial
+	resp[4] = 0
+	t.partial = nil
+	t.deliver(resp)
+}
+
+// deliver sends the complete response in buf to the waiting
+// transaction.
+func (t *transaction) deliver(buf []byte) {
+	select {
+	case t.resp <- buf:
+	default:
+	}
+}
+
+// matches returns whether buf is a response to the transaction's
+// command and measurement type.
+func (t *transaction) matches(buf []byte) bool {
+	return len(buf) >= 3 &&
+		buf[0] == controlPointResponse &&
+		Command(buf[1]) == t.command &&
+		(t.measure == anyMeasure || MeasureType(buf[2]&^0x80) == t.measure)
+}
+
+// transact writes msg, holding the command com for the measure
+// measurement type, to the control point and returns the notification
+// sent in response. Concurrent calls are serialised.
+func (cp *controlPoint) transact(ctx context.Context, com Command, measure MeasureType, msg []byte) ([]byte, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	t := &transaction{command: com, measure: measure, resp: make(chan []byte, 1)}
+	cp.pmu.Lock()
+	cp.pending = t
+	cp.pmu.Unlock()
+	defer func() {
+		cp.pmu.Lock()
+		cp.pending = nil
+		cp.pmu.Unlock()
+	}()
+
+	_, err := cp.char.WriteWithoutResponse(msg)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resp := <-t.resp:
+		return resp, nil
+	}
+}
+
+// close disables control point notifications.
+func (cp *controlPoint) close() error {
+	return cp.char.EnableNotifications(nil)
+}
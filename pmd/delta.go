@@ -0,0 +1,112 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"fmt"
+	"io"
+)
+
+// compressed is the frame type flag indicating that the frame
+// data is delta-compressed.
+const compressed = 0x80
+
+// isCompressed returns whether the frame type byte in a PMD
+// notification marks a delta-compressed frame, and the frame
+// type with the compression flag cleared.
+func isCompressed(typ byte) (FrameType, bool) {
+	return FrameType(typ &^ compressed), typ&compressed != 0
+}
+
+// deltaFrames decodes a delta-compressed PMD frame payload holding
+// samples with the given number of channels and bit resolution. The
+// returned samples are interleaved by channel, so sample i channel j
+// is at index i*channels+j.
+//
+// The payload is a reference sample of channels little-endian signed
+// values, each ceil(resolution/8) bytes wide, followed by zero or more
+// delta blocks. Each delta block is a bit width byte and a sample count
+// byte followed by count*channels packed signed deltas of the given bit
+// width, packed least significant bit first. Each delta is added to the
+// corresponding channel of the preceding sample.
+func deltaFrames(data []byte, channels, resolution int) ([]int32, error) {
+	if channels <= 0 {
+		return nil, fmt.Errorf("invalid number of channels: %d", channels)
+	}
+	if resolution <= 0 || resolution > 32 {
+		return nil, fmt.Errorf("invalid sample resolution: %d", resolution)
+	}
+	width := (resolution + 7) / 8
+	if len(data) < channels*width {
+		return nil, io.ErrUnexpectedEOF
+	}
+	samples := make([]int32, channels, channels*(1+len(data)/channels))
+	for i := range samples {
+		samples[i] = leIntN(data[i*width : (i+1)*width])
+	}
+	data = data[channels*width:]
+
+	for len(data) != 0 {
+		if len(data) < 2 {
+			return samples, io.ErrUnexpectedEOF
+		}
+		bits := int(data[0])
+		n := int(data[1])
+		data = data[2:]
+		if bits > 32 {
+			return samples, fmt.Errorf("invalid delta bit width: %d", bits)
+		}
+		size := (n*channels*bits + 7) / 8
+		if len(data) < size {
+			return samples, io.ErrUnexpectedEOF
+		}
+		r := bitReader{data: data[:size]}
+		for range n {
+			prev := samples[len(samples)-channels:]
+			for j := range channels {
+				samples = append(samples, prev[j]+r.signed(bits))
+			}
+		}
+		data = data[size:]
+	}
+	return samples, nil
+}
+
+// bitReader reads least significant bit first packed values.
+type bitReader struct {
+	data []byte
+	off  int // In bits.
+}
+
+// signed returns the next n-bit value in the stream as a sign-extended
+// integer.
+func (r *bitReader) signed(n int) int32 {
+	if n == 0 {
+		return 0
+	}
+	var v uint32
+	for i := range n {
+		bit := r.off + i
+		v |= uint32(r.data[bit/8]>>(bit%8)&1) << i
+	}
+	r.off += n
+	if n < 32 && v&(1<<(n-1)) != 0 {
+		v |= ^uint32(0) << n
+	}
+	return int32(v)
+}
+
+// leIntN returns the sign-extended little-endian value held in b,
+// which must be between one and four bytes long.
+func leIntN(b []byte) int32 {
+	var v uint32
+	for i, c := range b {
+		v |= uint32(c) << (8 * i)
+	}
+	if n := 8 * len(b); n < 32 && v&(1<<(n-1)) != 0 {
+		v |= ^uint32(0) << n
+	}
+	return int32(v)
+}
@@ -0,0 +1,178 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+var deltaFramesTests = []struct {
+	name       string
+	data       []byte
+	channels   int
+	resolution int
+	want       []int32
+	wantErr    error
+}{
+	{
+		name: "reference only",
+		data: []byte{
+			0xff, 0xff, 0xff, // -1
+			0x10, 0x00, 0x00, // 16
+		},
+		channels:   2,
+		resolution: 22,
+		want:       []int32{-1, 16},
+	},
+	{
+		name: "negative deltas",
+		data: []byte{
+			0x64, 0x00, // 100
+			0x04, 0x03, // 3 4-bit deltas
+			0xef, 0x03, // -1, -2, 3
+		},
+		channels:   1,
+		resolution: 16,
+		want:       []int32{100, 99, 97, 100},
+	},
+	{
+		name: "zero width block",
+		data: []byte{
+			0x01, 0x00, 0xff, 0xff, 0x02, 0x00, // 1, -1, 2
+			0x00, 0x02, // 2 0-bit deltas
+		},
+		channels:   3,
+		resolution: 16,
+		want:       []int32{1, -1, 2, 1, -1, 2, 1, -1, 2},
+	},
+	{
+		name: "several blocks",
+		data: []byte{
+			0x0a, 0xf6, // 10, -10
+			0x02, 0x02, // 2 2-bit deltas
+			0x6d,       // (1, -1), (-2, 1)
+			0x08, 0x01, // 1 8-bit delta
+			0x64, 0x9c, // (100, -100)
+		},
+		channels:   2,
+		resolution: 8,
+		want:       []int32{10, -10, 11, -11, 9, -10, 109, -110},
+	},
+	{
+		name: "32-bit deltas",
+		data: []byte{
+			0x00, 0x00, 0x00, 0x80, // -2147483648
+			0x20, 0x02, // 2 32-bit deltas
+			0xff, 0xff, 0xff, 0x7f, // 2147483647
+			0xff, 0xff, 0xff, 0xff, // -1
+		},
+		channels:   1,
+		resolution: 32,
+		want:       []int32{math.MinInt32, -1, -2},
+	},
+	{
+		name: "truncated reference",
+		data: []byte{
+			0x01, 0x00, 0x02,
+		},
+		channels:   2,
+		resolution: 16,
+		wantErr:    io.ErrUnexpectedEOF,
+	},
+	{
+		name: "truncated block header",
+		data: []byte{
+			0x00, 0x00, // 0
+			0x08,
+		},
+		channels:   1,
+		resolution: 16,
+		want:       []int32{0},
+		wantErr:    io.ErrUnexpectedEOF,
+	},
+	{
+		name: "truncated block",
+		data: []byte{
+			0x00, 0x00, // 0
+			0x08, 0x01, // 1 8-bit delta
+			0x05,       // 5
+			0x08, 0x03, // 3 8-bit deltas
+			0x01, 0x02,
+		},
+		channels:   1,
+		resolution: 16,
+		want:       []int32{0, 5},
+		wantErr:    io.ErrUnexpectedEOF,
+	},
+	{
+		name: "invalid bit width",
+		data: []byte{
+			0x00, 0x00, // 0
+			0x21, 0x01, // 1 33-bit delta
+			0x00, 0x00, 0x00, 0x00, 0x00,
+		},
+		channels:   1,
+		resolution: 16,
+		want:       []int32{0},
+		wantErr:    errAny,
+	},
+}
+
+// errAny matches any non-nil error in tests.
+var errAny = errors.New("any error")
+
+func TestDeltaFrames(t *testing.T) {
+	for _, test := range deltaFramesTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := deltaFrames(test.data, test.channels, test.resolution)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("unexpected samples:\ngot: %d\nwant:%d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBitReader(t *testing.T) {
+	// Values of widths 3, 32, 0, 5 and 32 packed
+	// least significant bit first without padding.
+	widths := []int{3, 32, 0, 5, 32}
+	want := []int32{-3, math.MinInt32, 0, 15, -2}
+	var (
+		data []byte
+		off  int
+	)
+	for i, n := range widths {
+		v := uint32(want[i])
+		for b := range n {
+			if off/8 == len(data) {
+				data = append(data, 0)
+			}
+			data[off/8] |= byte(v>>b&1) << (off % 8)
+			off++
+		}
+	}
+	r := bitReader{data: data}
+	for i, n := range widths {
+		got := r.signed(n)
+		if got != want[i] {
+			t.Errorf("unexpected value for %d-bit read %d: got:%d want:%d", n, i, got, want[i])
+		}
+	}
+	if r.off != off {
+		t.Errorf("unexpected offset after reads: got:%d want:%d", r.off, off)
+	}
+}
@@ -7,6 +7,8 @@ package pmd
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
+	"iter"
 	"time"
 )
 
@@ -33,28 +35,59 @@ func (h ECGHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
 
 // ECG is an ECG measurement.
 type ECG struct {
+	// Timestamp is the time of the last sample in Trace.
 	Timestamp time.Time
 	Trace     []int32 // µV
 }
 
+// SampleTime returns the time of sample i of the trace. Samples are
+// spaced at ECGSampleInterval and the last sample is at m.Timestamp.
+func (m ECG) SampleTime(i int) time.Time {
+	return sampleTime(m.Timestamp, i, len(m.Trace), ECGSampleInterval)
+}
+
+// Samples returns an iterator over the samples of the trace and their
+// times.
+func (m ECG) Samples() iter.Seq2[time.Time, int32] {
+	return func(yield func(time.Time, int32) bool) {
+		for i, v := range m.Trace {
+			if !yield(m.SampleTime(i), v) {
+				return
+			}
+		}
+	}
+}
+
 func (m *ECG) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
 	if MeasureType(data[sampleTypeOffset]) != ECGType {
 		return fmt.Errorf("expected sample type ecg: %v", data[sampleTypeOffset])
 	}
-	if FrameType(data[frameTypeOffset]) != ECGFrameType0 {
+	frameType, comp := isCompressed(data[frameTypeOffset])
+	if frameType != ECGFrameType0 {
 		return fmt.Errorf("expected frame type ecg: %v", data[frameTypeOffset])
 	}
 
 	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
 
 	trace := data[dataOffset:]
-	if len(trace)%ECGSamplingStride != 0 {
-		return fmt.Errorf("number of samples not a factor of 3: %v", len(trace)%3)
-	}
-
-	ecgTrace := make([]int32, 0, len(trace)/3)
-	for i := 0; i < len(trace); i += ECGSamplingStride {
-		ecgTrace = append(ecgTrace, leInt24(trace[i:i+ECGSamplingStride]))
+	var ecgTrace []int32
+	if comp {
+		var err error
+		ecgTrace, err = deltaFrames(trace, 1, ECGResolution)
+		if err != nil {
+			return fmt.Errorf("failed to decode compressed ecg frame: %w", err)
+		}
+	} else {
+		if len(trace)%ECGSamplingStride != 0 {
+			return fmt.Errorf("number of samples not a factor of 3: %v", len(trace)%3)
+		}
+		ecgTrace = make([]int32, 0, len(trace)/3)
+		for i := 0; i < len(trace); i += ECGSamplingStride {
+			ecgTrace = append(ecgTrace, leInt24(trace[i:i+ECGSamplingStride]))
+		}
 	}
 
 	*m = ECG{
@@ -0,0 +1,206 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// PressureHandler implements the Handler interface for barometric
+// pressure data.
+type PressureHandler struct {
+	// SampleFreq is the sample frequency to use.
+	SampleFreq uint16 // Hz
+
+	// Handler is called for each notification. The
+	// notification data can be decoded with PressureFrame.
+	Handler func([]byte)
+}
+
+func (h PressureHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
+	if h.Handler == nil {
+		return MeasureStop, PressureType, nil, nil
+	}
+	return MeasureStart, PressureType, []Setting{
+		Uint16{Type: SampleRateSetting, Val: []uint16{h.SampleFreq}}, // Hz
+		Uint16{Type: ResolutionSetting, Val: []uint16{32}},           // bits
+	}, h.Handler
+}
+
+// Pressure is a barometric pressure measurement.
+type Pressure struct {
+	Timestamp time.Time
+	Pressure  float32 // mBar
+}
+
+// PressureFrame is the set of pressure measurements held in a single
+// PMD notification.
+type PressureFrame struct {
+	// SampleFreq is the sample frequency of the stream.
+	// It must be set before calling UnmarshalBinary and
+	// is used to calculate the timestamp of each sample.
+	SampleFreq uint16 // Hz
+
+	// Timestamp is the time of the last sample.
+	Timestamp time.Time
+	Samples   []Pressure
+}
+
+// UnmarshalBinary decodes all the pressure samples in a PMD
+// notification. The PMD timestamp refers to the last sample in the
+// notification, so the timestamps of earlier samples are calculated
+// from m.SampleFreq.
+func (m *PressureFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != PressureType {
+		return fmt.Errorf("expected sample type pressure: %v", data[sampleTypeOffset])
+	}
+	if FrameType(data[frameTypeOffset]&^compressed) != PressureFrameType0 {
+		return fmt.Errorf("expected frame type pressure: %v", data[frameTypeOffset])
+	}
+	if m.SampleFreq == 0 {
+		return fmt.Errorf("pressure sample frequency not set")
+	}
+	values, err := float32Samples(data)
+	if err != nil {
+		return fmt.Errorf("invalid pressure frame: %w", err)
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+	last := time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9)
+
+	interval := time.Second / time.Duration(m.SampleFreq)
+	samples := make([]Pressure, len(values))
+	for i, v := range values {
+		samples[i] = Pressure{
+			Timestamp: sampleTime(last, i, len(values), interval),
+			Pressure:  v,
+		}
+	}
+
+	*m = PressureFrame{
+		SampleFreq: m.SampleFreq,
+		Timestamp:  last,
+		Samples:    samples,
+	}
+	return nil
+}
+
+// TemperatureHandler implements the Handler interface for temperature
+// data.
+type TemperatureHandler struct {
+	// SampleFreq is the sample frequency to use.
+	SampleFreq uint16 // Hz
+
+	// Handler is called for each notification. The
+	// notification data can be decoded with
+	// TemperatureFrame.
+	Handler func([]byte)
+}
+
+func (h TemperatureHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
+	if h.Handler == nil {
+		return MeasureStop, TemperatureType, nil, nil
+	}
+	return MeasureStart, TemperatureType, []Setting{
+		Uint16{Type: SampleRateSetting, Val: []uint16{h.SampleFreq}}, // Hz
+		Uint16{Type: ResolutionSetting, Val: []uint16{32}},           // bits
+	}, h.Handler
+}
+
+// Temperature is a temperature measurement.
+type Temperature struct {
+	Timestamp   time.Time
+	Temperature float32 // °C
+}
+
+// TemperatureFrame is the set of temperature measurements held in a
+// single PMD notification.
+type TemperatureFrame struct {
+	// SampleFreq is the sample frequency of the stream.
+	// It must be set before calling UnmarshalBinary and
+	// is used to calculate the timestamp of each sample.
+	SampleFreq uint16 // Hz
+
+	// Timestamp is the time of the last sample.
+	Timestamp time.Time
+	Samples   []Temperature
+}
+
+// UnmarshalBinary decodes all the temperature samples in a PMD
+// notification. The PMD timestamp refers to the last sample in the
+// notification, so the timestamps of earlier samples are calculated
+// from m.SampleFreq.
+func (m *TemperatureFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != TemperatureType {
+		return fmt.Errorf("expected sample type temperature: %v", data[sampleTypeOffset])
+	}
+	if FrameType(data[frameTypeOffset]&^compressed) != TemperatureFrameType0 {
+		return fmt.Errorf("expected frame type temperature: %v", data[frameTypeOffset])
+	}
+	if m.SampleFreq == 0 {
+		return fmt.Errorf("temperature sample frequency not set")
+	}
+	values, err := float32Samples(data)
+	if err != nil {
+		return fmt.Errorf("invalid temperature frame: %w", err)
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+	last := time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9)
+
+	interval := time.Second / time.Duration(m.SampleFreq)
+	samples := make([]Temperature, len(values))
+	for i, v := range values {
+		samples[i] = Temperature{
+			Timestamp:   sampleTime(last, i, len(values), interval),
+			Temperature: v,
+		}
+	}
+
+	*m = TemperatureFrame{
+		SampleFreq: m.SampleFreq,
+		Timestamp:  last,
+		Samples:    samples,
+	}
+	return nil
+}
+
+// float32Samples returns the single channel 32-bit floating point
+// samples held in the PMD notification in data.
+func float32Samples(data []byte) ([]float32, error) {
+	raw := data[dataOffset:]
+	if _, comp := isCompressed(data[frameTypeOffset]); comp {
+		// Compressed floating point frames are delta
+		// encoded on the bit representation of the
+		// values.
+		values, err := deltaFrames(raw, 1, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode compressed frame: %w", err)
+		}
+		samples := make([]float32, len(values))
+		for i, v := range values {
+			samples[i] = math.Float32frombits(uint32(v))
+		}
+		return samples, nil
+	}
+	if len(raw)%float32Size != 0 {
+		return nil, fmt.Errorf("number of samples not a factor of %d: %v", float32Size, len(raw)%float32Size)
+	}
+	samples := make([]float32, len(raw)/float32Size)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*float32Size:]))
+	}
+	return samples, nil
+}
@@ -0,0 +1,203 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	envSampleFreq = 10 // Hz
+	envInterval   = time.Second / envSampleFreq
+)
+
+var pressureFrameTests = []struct {
+	name    string
+	data    []byte
+	want    PressureFrame
+	wantErr error
+}{
+	{
+		name: "raw",
+		data: notification(PressureType, 0x00,
+			0x00, 0x50, 0x7d, 0x44, // 1013.25
+			0x00, 0x20, 0x7a, 0x44, // 1000.5
+		),
+		want: PressureFrame{
+			SampleFreq: envSampleFreq,
+			Timestamp:  testTime,
+			Samples: []Pressure{
+				{Timestamp: testTime.Add(-envInterval), Pressure: 1013.25},
+				{Timestamp: testTime, Pressure: 1000.5},
+			},
+		},
+	},
+	{
+		name: "compressed",
+		data: notification(PressureType, 0x80,
+			0x00, 0x50, 0x7d, 0x44, // 1013.25
+			0x00, 0x02, // 2 0-bit deltas
+		),
+		want: PressureFrame{
+			SampleFreq: envSampleFreq,
+			Timestamp:  testTime,
+			Samples: []Pressure{
+				{Timestamp: testTime.Add(-2 * envInterval), Pressure: 1013.25},
+				{Timestamp: testTime.Add(-envInterval), Pressure: 1013.25},
+				{Timestamp: testTime, Pressure: 1013.25},
+			},
+		},
+	},
+	{
+		name: "partial sample",
+		data: notification(PressureType, 0x00,
+			0x00, 0x50, 0x7d, 0x44,
+			0x00, 0x20,
+		),
+		wantErr: errAny,
+	},
+	{
+		name: "compressed truncated reference",
+		data: notification(PressureType, 0x80,
+			0x00, 0x50, 0x7d,
+		),
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "truncated header",
+		data:    notification(PressureType, 0x00)[:dataOffset-1],
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "wrong measurement type",
+		data:    notification(TemperatureType, 0x00, 0x00, 0x50, 0x7d, 0x44),
+		wantErr: errAny,
+	},
+	{
+		name:    "wrong frame type",
+		data:    notification(PressureType, 0x01, 0x00, 0x50, 0x7d, 0x44),
+		wantErr: errAny,
+	},
+}
+
+func TestPressureFrame(t *testing.T) {
+	for _, test := range pressureFrameTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := PressureFrame{SampleFreq: envSampleFreq}
+			err := got.UnmarshalBinary(test.data)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+				return
+			case err != nil:
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected frame:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
+
+var temperatureFrameTests = []struct {
+	name    string
+	data    []byte
+	want    TemperatureFrame
+	wantErr error
+}{
+	{
+		name: "raw",
+		data: notification(TemperatureType, 0x00,
+			0x00, 0x00, 0xa8, 0xc0, // -5.25
+		),
+		want: TemperatureFrame{
+			SampleFreq: envSampleFreq,
+			Timestamp:  testTime,
+			Samples: []Temperature{
+				{Timestamp: testTime, Temperature: -5.25},
+			},
+		},
+	},
+	{
+		name: "compressed",
+		data: notification(TemperatureType, 0x80,
+			0x00, 0x00, 0xac, 0x41, // 21.5
+			0x03, 0x01, // 1 3-bit delta
+			0x06, // -2
+		),
+		want: TemperatureFrame{
+			SampleFreq: envSampleFreq,
+			Timestamp:  testTime,
+			Samples: []Temperature{
+				{Timestamp: testTime.Add(-envInterval), Temperature: 21.5},
+				{Timestamp: testTime, Temperature: math.Float32frombits(0x41abfffe)},
+			},
+		},
+	},
+	{
+		name: "partial sample",
+		data: notification(TemperatureType, 0x00,
+			0x00, 0x00, 0xa8,
+		),
+		wantErr: errAny,
+	},
+	{
+		name: "compressed truncated block",
+		data: notification(TemperatureType, 0x80,
+			0x00, 0x00, 0xac, 0x41,
+			0x03,
+		),
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "truncated header",
+		data:    notification(TemperatureType, 0x00)[:dataOffset-1],
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "wrong measurement type",
+		data:    notification(PressureType, 0x00, 0x00, 0x00, 0xa8, 0xc0),
+		wantErr: errAny,
+	},
+	{
+		name:    "wrong frame type",
+		data:    notification(TemperatureType, 0x01, 0x00, 0x00, 0xa8, 0xc0),
+		wantErr: errAny,
+	},
+}
+
+func TestTemperatureFrame(t *testing.T) {
+	for _, test := range temperatureFrameTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := TemperatureFrame{SampleFreq: envSampleFreq}
+			err := got.UnmarshalBinary(test.data)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+				return
+			case err != nil:
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected frame:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
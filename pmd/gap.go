@@ -0,0 +1,71 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"math"
+	"time"
+)
+
+// Gap is a discontinuity between consecutive notifications of a
+// measurement stream.
+type Gap struct {
+	// Last is the time of the last sample before the gap
+	// and Next is the time of the first sample after it.
+	Last, Next time.Time
+
+	// Missing is the estimated number of samples missing
+	// from the stream. It is negative when the samples of
+	// consecutive notifications overlap.
+	Missing int
+}
+
+// Continuity checks the continuity of the samples of a measurement
+// stream across notifications. Gaps arise from dropped notifications
+// and from drift between the sensor's sample clock and its timestamp
+// clock.
+type Continuity struct {
+	// Interval is the expected interval between samples,
+	// for example ECGSampleInterval.
+	Interval time.Duration
+
+	// Tolerance is the largest difference from Interval
+	// between the last sample of a notification and the
+	// first sample of the next that is not reported as a
+	// gap. If Tolerance is zero, half of Interval is used.
+	Tolerance time.Duration
+
+	last time.Time
+}
+
+// Check records the times of the first and last samples of a
+// notification and returns any gap between first and the last sample
+// of the previously checked notification. The returned boolean is
+// true if there is a gap.
+func (c *Continuity) Check(first, last time.Time) (Gap, bool) {
+	prev := c.last
+	c.last = last
+	if prev.IsZero() {
+		return Gap{}, false
+	}
+	tol := c.Tolerance
+	if tol == 0 {
+		tol = c.Interval / 2
+	}
+	delta := first.Sub(prev) - c.Interval
+	if delta.Abs() <= tol {
+		return Gap{}, false
+	}
+	var missing int
+	if c.Interval > 0 {
+		missing = int(math.Round(float64(delta) / float64(c.Interval)))
+	}
+	return Gap{Last: prev, Next: first, Missing: missing}, true
+}
+
+// Reset clears the record of the previous notification.
+func (c *Continuity) Reset() {
+	c.last = time.Time{}
+}
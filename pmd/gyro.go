@@ -0,0 +1,193 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	GyroSampleFreq26  GyroSampleFreq = 26  // Hz
+	GyroSampleFreq52  GyroSampleFreq = 52  // Hz
+	GyroSampleFreq104 GyroSampleFreq = 104 // Hz
+	GyroSampleFreq208 GyroSampleFreq = 208 // Hz
+	GyroSampleFreq416 GyroSampleFreq = 416 // Hz
+
+	GyroRange250  GyroRange = 250  // deg/s
+	GyroRange500  GyroRange = 500  // deg/s
+	GyroRange1000 GyroRange = 1000 // deg/s
+	GyroRange2000 GyroRange = 2000 // deg/s
+
+	GyroResolution = 16 // bits
+)
+
+type GyroSampleFreq uint16
+
+type GyroRange uint16
+
+// GyroHandler implements the Handler interface for gyroscope data.
+type GyroHandler struct {
+	// SampleFreq is the sample frequency to use.
+	SampleFreq GyroSampleFreq
+	// Range is the angular velocity range to use.
+	Range GyroRange
+
+	// Handler is called for each notification. The
+	// notification data can be decoded with GyroFrame.
+	Handler func([]byte)
+}
+
+func (h GyroHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
+	if h.Handler == nil {
+		return MeasureStop, GyroType, nil, nil
+	}
+	return MeasureStart, GyroType, []Setting{
+		Uint16{Type: SampleRateSetting, Val: []uint16{uint16(h.SampleFreq)}}, // Hz
+		Uint16{Type: ResolutionSetting, Val: []uint16{GyroResolution}},       // bits
+		Uint16{Type: RangeUnitSetting, Val: []uint16{uint16(h.Range)}},       // deg/s
+	}, h.Handler
+}
+
+// Gyro is an angular velocity measurement.
+type Gyro struct {
+	Timestamp time.Time
+	X, Y, Z   float32 // deg/s
+}
+
+// GyroFrame is the set of angular velocity measurements held in a
+// single PMD notification.
+type GyroFrame struct {
+	// SampleFreq is the sample frequency of the stream.
+	// It must be set before calling UnmarshalBinary and
+	// is used to calculate the timestamp of each sample.
+	SampleFreq GyroSampleFreq
+	// Factor is the conversion factor from integer
+	// samples to deg/s returned by the sensor when
+	// the stream was started. It is obtained by calling
+	// ConversionFactor with the settings of the response
+	// returned by Listener.SetHandler. If Factor is zero,
+	// a factor of 1 is used.
+	Factor float32
+
+	// Timestamp is the time of the last sample.
+	Timestamp time.Time
+	Samples   []Gyro
+}
+
+// UnmarshalBinary decodes all the angular velocity samples in a PMD
+// notification. The PMD timestamp refers to the last sample in the
+// notification, so the timestamps of earlier samples are calculated
+// from m.SampleFreq.
+func (m *GyroFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != GyroType {
+		return fmt.Errorf("expected sample type gyro: %v", data[sampleTypeOffset])
+	}
+	if m.SampleFreq == 0 {
+		return fmt.Errorf("gyro sample frequency not set")
+	}
+	factor := m.Factor
+	if factor == 0 {
+		factor = 1
+	}
+
+	values, err := vectorSamples(data, GyroFrameType0, GyroFrameType1, factor)
+	if err != nil {
+		return fmt.Errorf("invalid gyro frame: %w", err)
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+	last := time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9)
+
+	interval := time.Second / time.Duration(m.SampleFreq)
+	n := len(values) / 3
+	samples := make([]Gyro, n)
+	for i := range samples {
+		samples[i] = Gyro{
+			Timestamp: sampleTime(last, i, n, interval),
+
+			X: values[3*i], Y: values[3*i+1], Z: values[3*i+2],
+		}
+	}
+
+	*m = GyroFrame{
+		SampleFreq: m.SampleFreq,
+		Factor:     m.Factor,
+		Timestamp:  last,
+		Samples:    samples,
+	}
+	return nil
+}
+
+// vectorSamples returns the X, Y, Z interleaved samples held in the
+// PMD notification in data for measurement types that encode scaled
+// 16-bit integer vectors with the intFrame frame type and 32-bit
+// floating point vectors with the floatFrame frame type. Integer
+// samples are scaled by factor.
+func vectorSamples(data []byte, intFrame, floatFrame FrameType, factor float32) ([]float32, error) {
+	frameType, comp := isCompressed(data[frameTypeOffset])
+	var (
+		values []int32
+		float  bool
+	)
+	switch frameType {
+	case intFrame:
+		if comp {
+			var err error
+			values, err = deltaFrames(data[dataOffset:], 3, 16)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode compressed frame: %w", err)
+			}
+			break
+		}
+		raw := data[dataOffset:]
+		if len(raw)%(3*uint16Size) != 0 {
+			return nil, fmt.Errorf("number of samples not a factor of %d: %v", 3*uint16Size, len(raw)%(3*uint16Size))
+		}
+		values = make([]int32, len(raw)/uint16Size)
+		for i := range values {
+			values[i] = int32(int16(binary.LittleEndian.Uint16(raw[i*uint16Size:])))
+		}
+	case floatFrame:
+		float = true
+		if comp {
+			// Compressed floating point frames are delta
+			// encoded on the bit representation of the
+			// values.
+			var err error
+			values, err = deltaFrames(data[dataOffset:], 3, 32)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode compressed frame: %w", err)
+			}
+			break
+		}
+		raw := data[dataOffset:]
+		if len(raw)%(3*float32Size) != 0 {
+			return nil, fmt.Errorf("number of samples not a factor of %d: %v", 3*float32Size, len(raw)%(3*float32Size))
+		}
+		values = make([]int32, len(raw)/float32Size)
+		for i := range values {
+			values[i] = int32(binary.LittleEndian.Uint32(raw[i*float32Size:]))
+		}
+	default:
+		return nil, fmt.Errorf("unexpected frame type: %v", data[frameTypeOffset])
+	}
+
+	samples := make([]float32, len(values))
+	for i, v := range values {
+		if float {
+			samples[i] = math.Float32frombits(uint32(v))
+		} else {
+			samples[i] = float32(v) * factor
+		}
+	}
+	return samples, nil
+}
@@ -0,0 +1,150 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const gyroInterval = time.Second / time.Duration(GyroSampleFreq52)
+
+var gyroFrameTests = []struct {
+	name    string
+	factor  float32
+	data    []byte
+	want    GyroFrame
+	wantErr error
+}{
+	{
+		name:   "raw type 0",
+		factor: 0.5,
+		data: notification(GyroType, 0x00,
+			0x02, 0x00, 0xfc, 0xff, 0x64, 0x00, // 2, -4, 100
+			0x00, 0x80, 0xff, 0x7f, 0x00, 0x00, // -32768, 32767, 0
+		),
+		want: GyroFrame{
+			SampleFreq: GyroSampleFreq52,
+			Factor:     0.5,
+			Timestamp:  testTime,
+			Samples: []Gyro{
+				{Timestamp: testTime.Add(-gyroInterval), X: 1, Y: -2, Z: 50},
+				{Timestamp: testTime, X: -16384, Y: 16383.5, Z: 0},
+			},
+		},
+	},
+	{
+		name: "compressed type 0 without factor",
+		data: notification(GyroType, 0x80,
+			0x0a, 0x00, 0xf6, 0xff, 0x00, 0x00, // 10, -10, 0
+			0x04, 0x01, // 1 4-bit delta
+			0x2f, 0x03, // (-1, 2, 3)
+		),
+		want: GyroFrame{
+			SampleFreq: GyroSampleFreq52,
+			Timestamp:  testTime,
+			Samples: []Gyro{
+				{Timestamp: testTime.Add(-gyroInterval), X: 10, Y: -10, Z: 0},
+				{Timestamp: testTime, X: 9, Y: -8, Z: 3},
+			},
+		},
+	},
+	{
+		name:   "raw type 1",
+		factor: 0.5,
+		data: notification(GyroType, 0x01,
+			0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x10, 0xc0, 0x00, 0x00, 0xc8, 0x42, // 1.5, -2.25, 100
+		),
+		want: GyroFrame{
+			SampleFreq: GyroSampleFreq52,
+			Factor:     0.5,
+			Timestamp:  testTime,
+			Samples: []Gyro{
+				{Timestamp: testTime, X: 1.5, Y: -2.25, Z: 100},
+			},
+		},
+	},
+	{
+		name: "compressed type 1",
+		data: notification(GyroType, 0x81,
+			0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x10, 0xc0, 0x00, 0x00, 0x00, 0x00, // 1.5, -2.25, 0
+			0x02, 0x01, // 1 2-bit delta
+			0x01, // (1, 0, 0)
+		),
+		want: GyroFrame{
+			SampleFreq: GyroSampleFreq52,
+			Timestamp:  testTime,
+			Samples: []Gyro{
+				{Timestamp: testTime.Add(-gyroInterval), X: 1.5, Y: -2.25, Z: 0},
+				{Timestamp: testTime, X: math.Float32frombits(0x3fc00001), Y: -2.25, Z: 0},
+			},
+		},
+	},
+	{
+		name: "raw type 0 partial sample",
+		data: notification(GyroType, 0x00,
+			0x02, 0x00, 0xfc, 0xff, 0x64, 0x00,
+			0x01, 0x00,
+		),
+		wantErr: errAny,
+	},
+	{
+		name: "raw type 1 partial sample",
+		data: notification(GyroType, 0x01,
+			0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x10, 0xc0,
+		),
+		wantErr: errAny,
+	},
+	{
+		name: "compressed type 1 truncated reference",
+		data: notification(GyroType, 0x81,
+			0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x10, 0xc0,
+		),
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "truncated header",
+		data:    notification(GyroType, 0x00)[:dataOffset-1],
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "wrong measurement type",
+		data:    notification(AccType, 0x00, 0x02, 0x00, 0xfc, 0xff, 0x64, 0x00),
+		wantErr: errAny,
+	},
+	{
+		name:    "wrong frame type",
+		data:    notification(GyroType, 0x02, 0x02, 0x00, 0xfc, 0xff, 0x64, 0x00),
+		wantErr: errAny,
+	},
+}
+
+func TestGyroFrame(t *testing.T) {
+	for _, test := range gyroFrameTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := GyroFrame{SampleFreq: GyroSampleFreq52, Factor: test.factor}
+			err := got.UnmarshalBinary(test.data)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+				return
+			case err != nil:
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected frame:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -6,27 +6,54 @@ package pmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 
-	"tinygo.org/x/bluetooth"
-
-	"github.com/kortschak/polar/internal/forkbeard"
+	"github.com/kortschak/polar/gatt"
 )
 
-// Listener implements PMD notification listening.
+// Listener implements PMD notification listening. It is safe for
+// concurrent use; control point commands are sent one at a time.
 type Listener struct {
-	dev *bluetooth.Device
+	dev gatt.Device
 
-	cpDevice, dataDevice bluetooth.DeviceCharacteristic
+	cp         *controlPoint
+	dataDevice gatt.Characteristic
 
 	features Features
 
+	// mu serialises changes to the stream state
+	// so that handlers agree with the commands
+	// sent to the sensor.
+	mu sync.Mutex
+
+	// hmu protects handlers and subs. The subs
+	// slices are replaced rather than modified so
+	// that they can be used after hmu is released.
+	hmu      sync.RWMutex
 	handlers [measurementTypes]func([]byte)
+	subs     [measurementTypes][]*Subscription
+
+	// started, settings and resp hold the online
+	// streams started by SetHandler or Subscribe,
+	// their settings and the sensor's response
+	// to the start command so that they can be
+	// shared by subscribers and restarted by
+	// Resume.
+	started  [measurementTypes]bool
+	settings [measurementTypes][]Setting
+	resp     [measurementTypes]ControlPointResponse
+
+	// sdk is whether SDK mode has been enabled by
+	// SetSDKMode so that it can be restored by
+	// Resume before streams are restarted.
+	sdk bool
 }
 
 // NewListener returns a new Listener for the provided Bluetooth device.
-func NewListener(dev *bluetooth.Device) (*Listener, error) {
-	cpDevice, err := forkbeard.DeviceCharacteristic(dev, pmdService, pmdCP)
+func NewListener(dev gatt.Device) (*Listener, error) {
+	cpDevice, err := dev.Characteristic(pmdService, pmdCP)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get device pmd control point characteristic: %w", err)
 	}
@@ -44,18 +71,23 @@ func NewListener(dev *bluetooth.Device) (*Listener, error) {
 	}
 	var feats Features
 	copy(feats[:], buf[:2])
-	dataDevice, err := forkbeard.DeviceCharacteristic(dev, pmdService, pmdData)
+	dataDevice, err := dev.Characteristic(pmdService, pmdData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get device pmd data characteristic: %w", err)
 	}
+	cp, err := newControlPoint(cpDevice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable control point notifications: %w", err)
+	}
 	l := &Listener{
 		dev:        dev,
-		cpDevice:   cpDevice,
+		cp:         cp,
 		features:   feats,
 		dataDevice: dataDevice,
 	}
 	err = dataDevice.EnableNotifications(l.dispatch)
 	if err != nil {
+		cp.close()
 		return nil, err
 	}
 	return l, nil
@@ -65,32 +97,260 @@ func (l *Listener) dispatch(buf []byte) {
 	if len(buf) == 0 {
 		return
 	}
-	handle := l.handlers[buf[sampleTypeOffset]]
+	typ := buf[sampleTypeOffset]
+	if int(typ) >= len(l.handlers) {
+		return
+	}
+	l.hmu.RLock()
+	handle := l.handlers[typ]
+	subs := l.subs[typ]
+	l.hmu.RUnlock()
 	if handle != nil {
 		handle(buf)
 	}
+	for _, sub := range subs {
+		sub.handle(buf)
+	}
 }
 
 // Settings returns the available setting for the recording and measurement type
 // of the sensor the Listener is connected to.
 func (l *Listener) Settings(ctx context.Context, m MeasureType) ([]Setting, error) {
-	return querySettings(ctx, l.cpDevice, MeasureSettings, Online, m)
+	return querySettings(ctx, l.cp, MeasureSettings, Online, m)
+}
+
+// OfflineSettings returns the available offline recording settings for
+// the measurement type of the sensor the Listener is connected to.
+func (l *Listener) OfflineSettings(ctx context.Context, m MeasureType) ([]Setting, error) {
+	return querySettings(ctx, l.cp, MeasureSettings, Offline, m)
+}
+
+// StartRecording starts an offline recording of the measurement type
+// on the sensor with the provided settings. The recording is held on
+// the sensor until it is retrieved; no notifications are sent for the
+// measurement while it is being recorded.
+func (l *Listener) StartRecording(ctx context.Context, m MeasureType, settings ...Setting) (ControlPointResponse, error) {
+	if int(m) >= len(l.handlers) {
+		return ControlPointResponse{}, fmt.Errorf("invalid measurement type: %d", m)
+	}
+	return sendCommand(ctx, l.cp, MeasureStart, Offline, m, settings...)
+}
+
+// StopRecording stops an offline recording of the measurement type on
+// the sensor.
+func (l *Listener) StopRecording(ctx context.Context, m MeasureType) (ControlPointResponse, error) {
+	if int(m) >= len(l.handlers) {
+		return ControlPointResponse{}, fmt.Errorf("invalid measurement type: %d", m)
+	}
+	return sendCommand(ctx, l.cp, MeasureStop, Offline, m)
+}
+
+// SetSDKMode enables or disables SDK mode on the sensor. SDK mode makes
+// a wider range of sample rates and ranges available on sensors that
+// support it. The sensor stops all running measurements when the mode
+// is changed, so SetSDKMode returns an error without changing the mode
+// if streams started by SetHandler or Subscribe are running; they must
+// be stopped before the mode is set. Setting the mode that the sensor
+// is already in is not an error.
+func (l *Listener) SetSDKMode(ctx context.Context, enable bool) error {
+	com := MeasureStop
+	if enable {
+		com = MeasureStart
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for m, ok := range l.started {
+		if ok {
+			return fmt.Errorf("cannot change sdk mode while measurement type %d is streaming", m)
+		}
+	}
+	_, err := sendCommand(ctx, l.cp, com, Online, SDKModeType)
+	if err != nil && !errors.Is(err, AlreadyInState) {
+		return err
+	}
+	l.sdk = enable
+	return nil
+}
+
+// SDKMode returns whether SDK mode is enabled on the sensor.
+func (l *Listener) SDKMode(ctx context.Context) (bool, error) {
+	resp, err := sendOp(ctx, l.cp, SDKModeStatus)
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Params) == 0 {
+		return false, fmt.Errorf("missing sdk mode status: %#x", resp.Params)
+	}
+	return resp.Params[0] != 0, nil
+}
+
+// SDKModeSettings returns the settings available for the measurement
+// type when the sensor is in SDK mode. The settings can be queried
+// whether or not SDK mode is enabled.
+func (l *Listener) SDKModeSettings(ctx context.Context, m MeasureType) ([]Setting, error) {
+	return querySettings(ctx, l.cp, SDKModeSettings, Online, m)
+}
+
+// ActiveMeasurements is the set of measurements running on a sensor.
+type ActiveMeasurements struct {
+	// Online holds the measurement types being
+	// streamed.
+	Online []MeasureType
+	// Offline holds the measurement types being
+	// recorded on the sensor.
+	Offline []MeasureType
+}
+
+// UnmarshalBinary decodes the parameters of a MeasureStatus response.
+// Each byte holds a measurement type in its low six bits and flags
+// indicating whether the measurement is being streamed and recorded. A
+// measurement that is both streamed and recorded is included in both
+// Online and Offline.
+func (a *ActiveMeasurements) UnmarshalBinary(data []byte) error {
+	var active ActiveMeasurements
+	for _, b := range data {
+		// | 0x80    | 0x40   | 0x3f |
+		// | offline | online | type |
+		m := MeasureType(b & 0x3f)
+		if b&0x40 != 0 {
+			active.Online = append(active.Online, m)
+		}
+		if b&0x80 != 0 {
+			active.Offline = append(active.Offline, m)
+		}
+	}
+	*a = active
+	return nil
+}
+
+// MeasurementStatus returns the measurements that are running on the
+// sensor the Listener is connected to, including streams started by
+// other clients or before a reconnection.
+func (l *Listener) MeasurementStatus(ctx context.Context) (ActiveMeasurements, error) {
+	resp, err := sendOp(ctx, l.cp, MeasureStatus)
+	if err != nil {
+		return ActiveMeasurements{}, err
+	}
+	var active ActiveMeasurements
+	err = active.UnmarshalBinary(resp.Params)
+	return active, err
 }
 
 // Set Handler sets the notification handler, command, recording type and
-// settings with the results of the h.Handler call.
-func (l *Listener) SetHandler(ctx context.Context, h Handler) ([]byte, error) {
+// settings with the results of the h.Handler call. If the sensor rejects
+// the command, the previous handler is restored and the returned error
+// is the response's Status.
+//
+// The handler set by SetHandler is called alongside the functions of
+// any subscriptions to the measurement type made with Subscribe. While
+// there are subscriptions, starting the stream does not send a command
+// and returns the response to the command that started the stream, and
+// stopping the stream only removes the handler, returning a zero
+// ControlPointResponse.
+func (l *Listener) SetHandler(ctx context.Context, h Handler) (ControlPointResponse, error) {
 	com, measureTyp, settings, handle := h.Handle()
 	if int(measureTyp) >= len(l.handlers) {
-		return nil, fmt.Errorf("invalid measurement type: %d", measureTyp)
+		return ControlPointResponse{}, fmt.Errorf("invalid measurement type: %d", measureTyp)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.started[measureTyp] && l.subscribed(measureTyp) {
+		switch com {
+		case MeasureStart:
+			err := l.checkSettings(measureTyp, settings)
+			if err != nil {
+				return ControlPointResponse{}, err
+			}
+			l.setHandler(measureTyp, handle)
+			return l.resp[measureTyp], nil
+		case MeasureStop:
+			l.setHandler(measureTyp, nil)
+			return ControlPointResponse{}, nil
+		}
 	}
-	l.handlers[measureTyp] = handle
-	return sendCommand(ctx, l.cpDevice, com, Online, measureTyp, settings...)
+	// The handler is set before the command is sent
+	// since data may arrive before the response.
+	prev := l.setHandler(measureTyp, handle)
+	resp, err := sendCommand(ctx, l.cp, com, Online, measureTyp, settings...)
+	if err != nil {
+		l.setHandler(measureTyp, prev)
+		return resp, err
+	}
+	switch com {
+	case MeasureStart:
+		l.started[measureTyp] = true
+		l.settings[measureTyp] = settings
+		l.resp[measureTyp] = resp
+	case MeasureStop:
+		l.started[measureTyp] = false
+		l.settings[measureTyp] = nil
+		l.resp[measureTyp] = ControlPointResponse{}
+	}
+	return resp, nil
+}
+
+// Resume restarts the online streams started by SetHandler or Subscribe
+// with their original settings, retaining their handlers and
+// subscriptions. It is intended to be called after a lost connection
+// has been re-established by a device that restores the Listener's
+// characteristics, such as a reconnect.Supervisor. If SDK mode was
+// enabled with SetSDKMode, it is enabled again before the streams are
+// restarted. Streams that the sensor reports as already running are
+// left running.
+func (l *Listener) Resume(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sdk {
+		_, err := sendCommand(ctx, l.cp, MeasureStart, Online, SDKModeType)
+		if err != nil && !errors.Is(err, AlreadyInState) {
+			return fmt.Errorf("failed to resume sdk mode: %w", err)
+		}
+	}
+	for m, ok := range l.started {
+		if !ok {
+			continue
+		}
+		resp, err := sendCommand(ctx, l.cp, MeasureStart, Online, MeasureType(m), l.settings[m]...)
+		if err != nil {
+			if errors.Is(err, AlreadyInState) {
+				continue
+			}
+			return fmt.Errorf("failed to resume measurement type %d: %w", m, err)
+		}
+		l.resp[m] = resp
+	}
+	return nil
+}
+
+// Streaming returns whether the Listener has handlers set by SetHandler
+// or subscriptions made with Subscribe, and so expects notifications
+// from the sensor. It can be used with reconnect.Supervisor.Watch so
+// that stopped streams are not treated as a lost connection.
+func (l *Listener) Streaming() bool {
+	l.hmu.RLock()
+	defer l.hmu.RUnlock()
+	for m, h := range l.handlers {
+		if h != nil || len(l.subs[m]) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setHandler sets the handler for the measurement type and returns the
+// previous handler.
+func (l *Listener) setHandler(m MeasureType, h func([]byte)) func([]byte) {
+	l.hmu.Lock()
+	defer l.hmu.Unlock()
+	prev := l.handlers[m]
+	l.handlers[m] = h
+	return prev
 }
 
 // Close disables notifications and disconnects the device.
 func (l *Listener) Close() error {
 	l.dataDevice.EnableNotifications(nil)
+	l.cp.close()
 	return l.dev.Disconnect()
 }
 
@@ -0,0 +1,60 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+var activeMeasurementsTests = []struct {
+	name string
+	data []byte
+	want ActiveMeasurements
+}{
+	{
+		name: "none",
+		data: nil,
+		want: ActiveMeasurements{},
+	},
+	{
+		name: "online only",
+		data: []byte{0x40 | byte(ECGType), 0x40 | byte(AccType)},
+		want: ActiveMeasurements{Online: []MeasureType{ECGType, AccType}},
+	},
+	{
+		name: "offline only",
+		data: []byte{0x80 | byte(PPIType)},
+		want: ActiveMeasurements{Offline: []MeasureType{PPIType}},
+	},
+	{
+		name: "online and offline",
+		data: []byte{0xc0 | byte(AccType)},
+		want: ActiveMeasurements{Online: []MeasureType{AccType}, Offline: []MeasureType{AccType}},
+	},
+	{
+		name: "mixed",
+		data: []byte{0x40 | byte(PPGType), 0x80 | byte(PPIType), 0xc0 | byte(GyroType), byte(MagnetometerType)},
+		want: ActiveMeasurements{
+			Online:  []MeasureType{PPGType, GyroType},
+			Offline: []MeasureType{PPIType, GyroType},
+		},
+	},
+}
+
+func TestActiveMeasurements(t *testing.T) {
+	for _, test := range activeMeasurementsTests {
+		t.Run(test.name, func(t *testing.T) {
+			var got ActiveMeasurements
+			err := got.UnmarshalBinary(test.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected result:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	MagSampleFreq10  MagSampleFreq = 10  // Hz
+	MagSampleFreq20  MagSampleFreq = 20  // Hz
+	MagSampleFreq50  MagSampleFreq = 50  // Hz
+	MagSampleFreq100 MagSampleFreq = 100 // Hz
+
+	MagRange50G MagRange = 50 // G
+
+	MagResolution = 16 // bits
+)
+
+type MagSampleFreq uint16
+
+type MagRange uint16
+
+// MagCalibration is the calibration status of a magnetometer sample.
+type MagCalibration uint16
+
+const (
+	MagCalibrationUnknown MagCalibration = 0
+	MagCalibrationPoor    MagCalibration = 1
+	MagCalibrationOK      MagCalibration = 2
+	MagCalibrationGood    MagCalibration = 3
+)
+
+// MagHandler implements the Handler interface for magnetometer data.
+type MagHandler struct {
+	// SampleFreq is the sample frequency to use.
+	SampleFreq MagSampleFreq
+	// Range is the magnetic field range to use.
+	Range MagRange
+
+	// Handler is called for each notification. The
+	// notification data can be decoded with MagFrame.
+	Handler func([]byte)
+}
+
+func (h MagHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
+	if h.Handler == nil {
+		return MeasureStop, MagnetometerType, nil, nil
+	}
+	return MeasureStart, MagnetometerType, []Setting{
+		Uint16{Type: SampleRateSetting, Val: []uint16{uint16(h.SampleFreq)}}, // Hz
+		Uint16{Type: ResolutionSetting, Val: []uint16{MagResolution}},        // bits
+		Uint16{Type: RangeUnitSetting, Val: []uint16{uint16(h.Range)}},       // G
+	}, h.Handler
+}
+
+// Mag is a magnetic field measurement.
+type Mag struct {
+	Timestamp time.Time
+	X, Y, Z   float32 // G
+
+	// Calibration is the calibration status of the
+	// sensor. It is only available from frame type 1
+	// notifications.
+	Calibration MagCalibration
+}
+
+// MagFrame is the set of magnetic field measurements held in a single
+// PMD notification.
+type MagFrame struct {
+	// SampleFreq is the sample frequency of the stream.
+	// It must be set before calling UnmarshalBinary and
+	// is used to calculate the timestamp of each sample.
+	SampleFreq MagSampleFreq
+	// Factor is the conversion factor from integer
+	// samples to Gauss returned by the sensor when
+	// the stream was started. It is obtained by calling
+	// ConversionFactor with the settings of the response
+	// returned by Listener.SetHandler. If Factor is zero,
+	// a factor of 1 is used.
+	Factor float32
+
+	// Timestamp is the time of the last sample.
+	Timestamp time.Time
+	Samples   []Mag
+}
+
+// UnmarshalBinary decodes all the magnetic field samples in a PMD
+// notification. The PMD timestamp refers to the last sample in the
+// notification, so the timestamps of earlier samples are calculated
+// from m.SampleFreq.
+func (m *MagFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != MagnetometerType {
+		return fmt.Errorf("expected sample type mag: %v", data[sampleTypeOffset])
+	}
+	if m.SampleFreq == 0 {
+		return fmt.Errorf("mag sample frequency not set")
+	}
+	factor := m.Factor
+	if factor == 0 {
+		factor = 1
+	}
+
+	// Frame type 0 samples are X, Y, Z and frame
+	// type 1 samples are X, Y, Z, calibration status.
+	frameType, comp := isCompressed(data[frameTypeOffset])
+	var channels int
+	switch frameType {
+	case MagnetometerFrameType0:
+		channels = 3
+	case MagnetometerFrameType1:
+		channels = 4
+	default:
+		return fmt.Errorf("expected frame type mag0/mag1: %v", data[frameTypeOffset])
+	}
+	var values []int32
+	raw := data[dataOffset:]
+	if comp {
+		var err error
+		values, err = deltaFrames(raw, channels, MagResolution)
+		if err != nil {
+			return fmt.Errorf("failed to decode compressed mag frame: %w", err)
+		}
+	} else {
+		if len(raw)%(channels*uint16Size) != 0 {
+			return fmt.Errorf("number of samples not a factor of %d: %v", channels*uint16Size, len(raw)%(channels*uint16Size))
+		}
+		values = make([]int32, len(raw)/uint16Size)
+		for i := range values {
+			values[i] = int32(int16(binary.LittleEndian.Uint16(raw[i*uint16Size:])))
+		}
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+	last := time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9)
+
+	interval := time.Second / time.Duration(m.SampleFreq)
+	n := len(values) / channels
+	samples := make([]Mag, n)
+	for i := range samples {
+		v := values[i*channels : (i+1)*channels]
+		samples[i] = Mag{
+			Timestamp: sampleTime(last, i, n, interval),
+
+			X: float32(v[0]) * factor,
+			Y: float32(v[1]) * factor,
+			Z: float32(v[2]) * factor,
+		}
+		if channels == 4 {
+			samples[i].Calibration = MagCalibration(v[3])
+		}
+	}
+
+	*m = MagFrame{
+		SampleFreq: m.SampleFreq,
+		Factor:     m.Factor,
+		Timestamp:  last,
+		Samples:    samples,
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const magInterval = time.Second / time.Duration(MagSampleFreq50)
+
+var magFrameTests = []struct {
+	name    string
+	factor  float32
+	data    []byte
+	want    MagFrame
+	wantErr error
+}{
+	{
+		name:   "raw type 0",
+		factor: 0.25,
+		data: notification(MagnetometerType, 0x00,
+			0x04, 0x00, 0xf8, 0xff, 0x90, 0x01, // 4, -8, 400
+		),
+		want: MagFrame{
+			SampleFreq: MagSampleFreq50,
+			Factor:     0.25,
+			Timestamp:  testTime,
+			Samples: []Mag{
+				{Timestamp: testTime, X: 1, Y: -2, Z: 100},
+			},
+		},
+	},
+	{
+		name:   "raw type 1",
+		factor: 0.5,
+		data: notification(MagnetometerType, 0x01,
+			0x02, 0x00, 0xfe, 0xff, 0x00, 0x00, 0x02, 0x00, // 2, -2, 0, ok
+			0x0a, 0x00, 0x00, 0x00, 0xf6, 0xff, 0x01, 0x00, // 10, 0, -10, poor
+		),
+		want: MagFrame{
+			SampleFreq: MagSampleFreq50,
+			Factor:     0.5,
+			Timestamp:  testTime,
+			Samples: []Mag{
+				{Timestamp: testTime.Add(-magInterval), X: 1, Y: -1, Z: 0, Calibration: MagCalibrationOK},
+				{Timestamp: testTime, X: 5, Y: 0, Z: -5, Calibration: MagCalibrationPoor},
+			},
+		},
+	},
+	{
+		name: "compressed type 1 without factor",
+		data: notification(MagnetometerType, 0x81,
+			0x64, 0x00, 0x9c, 0xff, 0x00, 0x00, 0x03, 0x00, // 100, -100, 0, good
+			0x02, 0x01, // 1 2-bit delta
+			0x0d, // (1, -1, 0, 0)
+		),
+		want: MagFrame{
+			SampleFreq: MagSampleFreq50,
+			Timestamp:  testTime,
+			Samples: []Mag{
+				{Timestamp: testTime.Add(-magInterval), X: 100, Y: -100, Z: 0, Calibration: MagCalibrationGood},
+				{Timestamp: testTime, X: 101, Y: -101, Z: 0, Calibration: MagCalibrationGood},
+			},
+		},
+	},
+	{
+		name: "raw type 1 partial sample",
+		data: notification(MagnetometerType, 0x01,
+			0x02, 0x00, 0xfe, 0xff, 0x00, 0x00,
+		),
+		wantErr: errAny,
+	},
+	{
+		name: "compressed type 1 truncated block",
+		data: notification(MagnetometerType, 0x81,
+			0x64, 0x00, 0x9c, 0xff, 0x00, 0x00, 0x03, 0x00,
+			0x08, 0x01, // 1 8-bit delta
+			0x01, 0x02,
+		),
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "truncated header",
+		data:    notification(MagnetometerType, 0x00)[:dataOffset-1],
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "wrong measurement type",
+		data:    notification(GyroType, 0x00, 0x04, 0x00, 0xf8, 0xff, 0x90, 0x01),
+		wantErr: errAny,
+	},
+	{
+		name:    "wrong frame type",
+		data:    notification(MagnetometerType, 0x02, 0x04, 0x00, 0xf8, 0xff, 0x90, 0x01),
+		wantErr: errAny,
+	},
+}
+
+func TestMagFrame(t *testing.T) {
+	for _, test := range magFrameTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := MagFrame{SampleFreq: MagSampleFreq50, Factor: test.factor}
+			err := got.UnmarshalBinary(test.data)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+				return
+			case err != nil:
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected frame:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
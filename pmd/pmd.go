@@ -12,28 +12,28 @@
 package pmd
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
 	"strings"
+	"time"
 
 	"tinygo.org/x/bluetooth"
 )
 
 // Service and characteristic identifiers.
 const (
-	pmdServiceID      = "fb005c80-02e7-f387-1cad-8acd2d8df0c8"
-	pmdControlPointID = "fb005c81-02e7-f387-1cad-8acd2d8df0c8"
-	pmdDataID         = "fb005c82-02e7-f387-1cad-8acd2d8df0c8"
+	ServiceID      = "fb005c80-02e7-f387-1cad-8acd2d8df0c8"
+	ControlPointID = "fb005c81-02e7-f387-1cad-8acd2d8df0c8"
+	DataID         = "fb005c82-02e7-f387-1cad-8acd2d8df0c8"
 )
 
 var (
-	pmdService = must(bluetooth.ParseUUID(pmdServiceID))
-	pmdCP      = must(bluetooth.ParseUUID(pmdControlPointID))
-	pmdData    = must(bluetooth.ParseUUID(pmdDataID))
+	pmdService = must(bluetooth.ParseUUID(ServiceID))
+	pmdCP      = must(bluetooth.ParseUUID(ControlPointID))
+	pmdData    = must(bluetooth.ParseUUID(DataID))
 )
 
 func must[T any](v T, err error) T {
@@ -48,7 +48,7 @@ type Features [2]byte
 
 func (f Features) String() string {
 	if f[0] != 0xf {
-		return fmt.Sprintf("%#x", f)
+		return fmt.Sprintf("%#x", [2]byte(f))
 	}
 	var s strings.Builder
 	for b := 1; b < 256; b <<= 1 {
@@ -85,6 +85,13 @@ const (
 	MeasureSettings Command = 1
 	MeasureStart    Command = 2
 	MeasureStop     Command = 3
+	SDKModeSettings Command = 4
+	MeasureStatus   Command = 5
+	SDKModeStatus   Command = 6
+
+	OfflineTriggerStatus   Command = 7
+	OfflineTriggerMode     Command = 8
+	OfflineTriggerSettings Command = 9
 )
 
 // RecordingType is a PMD recording mode type.
@@ -131,6 +138,7 @@ const (
 
 	MagnetometerType       MeasureType = 6
 	MagnetometerFrameType0 FrameType   = 0
+	MagnetometerFrameType1 FrameType   = 1
 
 	SDKModeType MeasureType = 9
 
@@ -153,41 +161,25 @@ const (
 	dataOffset       = 10
 )
 
-func querySettings(ctx context.Context, dev bluetooth.DeviceCharacteristic, com Command, rec RecordingType, measure MeasureType) ([]Setting, error) {
-	msg := make([]byte, settingSize(setCommand{}))
-	off := 0
-	_, err := setCommand{
-		Command: com,
-		Record:  rec,
-		Measure: measure,
-	}.write(msg[off:])
+func querySettings(ctx context.Context, cp *controlPoint, com Command, rec RecordingType, measure MeasureType) ([]Setting, error) {
+	resp, err := sendCommand(ctx, cp, com, rec, measure)
 	if err != nil {
 		return nil, err
 	}
-	var settings []byte
-	done := make(chan struct{})
-	dev.EnableNotifications(func(buf []byte) {
-		settings = bytes.Clone(buf)
-		close(done)
-	})
-	dev.WriteWithoutResponse(msg)
-	select {
-	case <-ctx.Done():
-		err = ctx.Err()
-	case <-done:
-	}
-	dev.EnableNotifications(nil)
-	if len(settings) < 5 {
-		return nil, fmt.Errorf("short response: %#x", settings)
-	}
-	if settings[0] != 0xf0 || Command(settings[1]) != com {
-		return nil, fmt.Errorf("invalid response: %#x", settings)
-	}
-	if settings[3] != 0 {
-		// https://www.bluetooth.com/wp-content/uploads/Files/Specification/HTML/Core-54/out/en/host/attribute-protocol--att-.html#UUID-5a07e398-0e4d-af25-0243-2b45ebfbda5b
-		return nil, fmt.Errorf("invalid response: %#x", settings[:5])
-	}
-	return parseSetting(settings[5:])
+	return resp.Settings()
+}
+
+// ConversionFactor returns the value of the ConversionFactorSetting
+// in settings. If settings does not hold a conversion factor, 1 is
+// returned.
+func ConversionFactor(settings []Setting) float32 {
+	for _, s := range settings {
+		f, ok := s.(Float32)
+		if ok && f.Type == ConversionFactorSetting && len(f.Val) != 0 {
+			return f.Val[0]
+		}
+	}
+	return 1
 }
 
 func parseSetting(data []byte) ([]Setting, error) {
@@ -225,7 +217,7 @@ func parseSetting(data []byte) ([]Setting, error) {
 	return settings, nil
 }
 
-func sendCommand(ctx context.Context, dev bluetooth.DeviceCharacteristic, com Command, rec RecordingType, measure MeasureType, settings ...Setting) ([]byte, error) {
+func sendCommand(ctx context.Context, cp *controlPoint, com Command, rec RecordingType, measure MeasureType, settings ...Setting) (ControlPointResponse, error) {
 	msg := make([]byte, settingSize(setCommand{})+settingSize(settings...))
 	off := 0
 	n, err := setCommand{
@@ -234,30 +226,30 @@ func sendCommand(ctx context.Context, dev bluetooth.DeviceCharacteristic, com Co
 		Measure: measure,
 	}.write(msg[off:])
 	if err != nil {
-		return nil, err
+		return ControlPointResponse{}, err
 	}
 	off += n
 	for _, w := range settings {
 		n, err := w.write(msg[off:])
 		if err != nil {
-			return nil, err
+			return ControlPointResponse{}, err
 		}
 		off += n
 	}
-	var resp []byte
-	done := make(chan struct{})
-	dev.EnableNotifications(func(buf []byte) {
-		resp = bytes.Clone(buf)
-		close(done)
-	})
-	dev.WriteWithoutResponse(msg)
-	select {
-	case <-ctx.Done():
-		err = ctx.Err()
-	case <-done:
-	}
-	dev.EnableNotifications(nil)
-	return resp, err
+	buf, err := cp.transact(ctx, com, measure, msg)
+	if err != nil {
+		return ControlPointResponse{}, err
+	}
+	var resp ControlPointResponse
+	err = resp.UnmarshalBinary(buf)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Command != com || resp.Measure != measure {
+		return resp, fmt.Errorf("invalid response: %#x", buf)
+	}
+	// https://www.bluetooth.com/wp-content/uploads/Files/Specification/HTML/Core-54/out/en/host/attribute-protocol--att-.html#UUID-5a07e398-0e4d-af25-0243-2b45ebfbda5b
+	return resp, resp.Err()
 }
 
 // SettingType specifies PMD measurement settings.
@@ -299,6 +291,37 @@ const (
 	float32Size = 4
 )
 
+// sendOp sends the control point command com with the provided
+// parameters for commands that do not apply to a single measurement
+// type, and returns the response.
+func sendOp(ctx context.Context, cp *controlPoint, com Command, params ...byte) (ControlPointResponse, error) {
+	msg := append([]byte{byte(com)}, params...)
+	buf, err := cp.transact(ctx, com, anyMeasure, msg)
+	if err != nil {
+		return ControlPointResponse{}, err
+	}
+	var resp ControlPointResponse
+	err = resp.UnmarshalBinary(buf)
+	if err != nil {
+		return resp, err
+	}
+	return resp, resp.Err()
+}
+
+// encodeSettings returns the control point encoding of settings.
+func encodeSettings(settings ...Setting) ([]byte, error) {
+	buf := make([]byte, settingSize(settings...))
+	off := 0
+	for _, w := range settings {
+		n, err := w.write(buf[off:])
+		if err != nil {
+			return nil, err
+		}
+		off += n
+	}
+	return buf, nil
+}
+
 func settingSize(s ...Setting) int {
 	var n int
 	for _, t := range s {
@@ -471,6 +494,12 @@ func (w *Float32) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// sampleTime returns the time of sample i of n samples spaced by interval
+// where last is the time of the final sample.
+func sampleTime(last time.Time, i, n int, interval time.Duration) time.Time {
+	return last.Add(-time.Duration(n-1-i) * interval)
+}
+
 func leInt24(b []byte) int32 {
 	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
 	return int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
@@ -0,0 +1,204 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	PPGSampleFreq28  PPGSampleFreq = 28  // Hz
+	PPGSampleFreq44  PPGSampleFreq = 44  // Hz
+	PPGSampleFreq55  PPGSampleFreq = 55  // Hz
+	PPGSampleFreq130 PPGSampleFreq = 130 // Hz
+	PPGSampleFreq135 PPGSampleFreq = 135 // Hz
+	PPGSampleFreq176 PPGSampleFreq = 176 // Hz
+
+	PPGResolution = 22 // bits
+)
+
+type PPGSampleFreq uint16
+
+// PPGHandler implements the Handler interface for optical PPG data.
+type PPGHandler struct {
+	// SampleFreq is the sample frequency to use.
+	SampleFreq PPGSampleFreq
+	// Channels is the number of channels to use.
+	// If Channels is zero, the sensor default is
+	// used.
+	Channels uint8
+
+	// Handler is called for each notification. The
+	// notification data can be decoded with PPGFrame.
+	Handler func([]byte)
+}
+
+func (h PPGHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
+	if h.Handler == nil {
+		return MeasureStop, PPGType, nil, nil
+	}
+	settings := []Setting{
+		Uint16{Type: SampleRateSetting, Val: []uint16{uint16(h.SampleFreq)}}, // Hz
+		Uint16{Type: ResolutionSetting, Val: []uint16{PPGResolution}},        // bits
+	}
+	if h.Channels != 0 {
+		settings = append(settings, Uint8{Type: ChannelsSetting, Val: []uint8{h.Channels}})
+	}
+	return MeasureStart, PPGType, settings, h.Handler
+}
+
+// PPG is an optical PPG measurement. The fields that are populated
+// depend on the frame type of the notification holding the sample.
+//
+//   - Frame type 0 samples hold three optical channels and the ambient
+//     light channel.
+//   - Frame type 5 samples hold the sensor operation mode.
+//   - Frame type 7 and 8 samples hold 16 and 24 optical channels
+//     respectively, and the sensor status.
+//
+// Frame types 4, 6 and 9 are not supported and are reported as an error
+// by PPGFrame.UnmarshalBinary.
+type PPG struct {
+	Timestamp time.Time
+
+	Channels      []int32
+	Ambient       int32
+	Status        uint32
+	OperationMode uint32
+}
+
+// PPGFrame is the set of PPG measurements held in a single PMD
+// notification.
+type PPGFrame struct {
+	// SampleFreq is the sample frequency of the stream.
+	// It must be set before calling UnmarshalBinary and
+	// is used to calculate the timestamp of each sample.
+	SampleFreq PPGSampleFreq
+
+	// Timestamp is the time of the last sample.
+	Timestamp time.Time
+	// FrameType is the frame type of the notification
+	// with the compression flag cleared.
+	FrameType FrameType
+	Samples   []PPG
+}
+
+// ppgLayout describes the channel layout of a PPG frame type.
+type ppgLayout struct {
+	channels   int  // Total number of channels in each sample.
+	optical    int  // Number of optical channels.
+	ambient    bool // Last channel is ambient light.
+	status     bool // Last channel is sensor status.
+	resolution int  // Bits per channel.
+}
+
+var (
+	ppgRawLayouts = map[FrameType]ppgLayout{
+		PPGFrameType0: {channels: 4, optical: 3, ambient: true, resolution: 24},
+	}
+	ppgCompressedLayouts = map[FrameType]ppgLayout{
+		PPGFrameType0: {channels: 4, optical: 3, ambient: true, resolution: PPGResolution},
+		PPGFrameType7: {channels: 17, optical: 16, status: true, resolution: 24},
+		PPGFrameType8: {channels: 25, optical: 24, status: true, resolution: 24},
+	}
+)
+
+// UnmarshalBinary decodes all the PPG samples in a PMD notification.
+// The PMD timestamp refers to the last sample in the notification, so
+// the timestamps of earlier samples are calculated from m.SampleFreq.
+// Raw frame types 0 and 5 and compressed frame types 0, 7 and 8 are
+// supported.
+func (m *PPGFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != PPGType {
+		return fmt.Errorf("expected sample type ppg: %v", data[sampleTypeOffset])
+	}
+	if m.SampleFreq == 0 {
+		return fmt.Errorf("ppg sample frequency not set")
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+	last := time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9)
+	interval := time.Second / time.Duration(m.SampleFreq)
+
+	frameType, comp := isCompressed(data[frameTypeOffset])
+	raw := data[dataOffset:]
+	var samples []PPG
+	if !comp && frameType == PPGFrameType5 {
+		if len(raw)%4 != 0 {
+			return fmt.Errorf("number of samples not a factor of 4: %v", len(raw)%4)
+		}
+		n := len(raw) / 4
+		samples = make([]PPG, n)
+		for i := range samples {
+			samples[i] = PPG{
+				Timestamp:     sampleTime(last, i, n, interval),
+				OperationMode: binary.LittleEndian.Uint32(raw[4*i:]),
+			}
+		}
+	} else {
+		layouts := ppgRawLayouts
+		if comp {
+			layouts = ppgCompressedLayouts
+		}
+		layout, ok := layouts[frameType]
+		if !ok {
+			return fmt.Errorf("unsupported ppg frame type: %v", data[frameTypeOffset])
+		}
+		values, err := layout.samples(raw, comp)
+		if err != nil {
+			return err
+		}
+		n := len(values) / layout.channels
+		samples = make([]PPG, n)
+		for i := range samples {
+			v := values[i*layout.channels : (i+1)*layout.channels]
+			s := PPG{
+				Timestamp: sampleTime(last, i, n, interval),
+				Channels:  v[:layout.optical:layout.optical],
+			}
+			switch {
+			case layout.ambient:
+				s.Ambient = v[layout.optical]
+			case layout.status:
+				s.Status = uint32(v[layout.optical])
+			}
+			samples[i] = s
+		}
+	}
+
+	*m = PPGFrame{
+		SampleFreq: m.SampleFreq,
+		Timestamp:  last,
+		FrameType:  frameType,
+		Samples:    samples,
+	}
+	return nil
+}
+
+// samples returns the channel interleaved samples in data.
+func (l ppgLayout) samples(data []byte, comp bool) ([]int32, error) {
+	if comp {
+		values, err := deltaFrames(data, l.channels, l.resolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode compressed ppg frame: %w", err)
+		}
+		return values, nil
+	}
+	size := (l.resolution + 7) / 8
+	if len(data)%(l.channels*size) != 0 {
+		return nil, fmt.Errorf("number of samples not a factor of %d: %v", l.channels*size, len(data)%(l.channels*size))
+	}
+	values := make([]int32, len(data)/size)
+	for i := range values {
+		values[i] = leIntN(data[i*size : (i+1)*size])
+	}
+	return values, nil
+}
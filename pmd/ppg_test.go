@@ -0,0 +1,203 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testTime is the time of the notifications built by notification.
+var testTime = time.Unix(epoch+1, 0)
+
+// notification returns a PMD notification of the measurement type with
+// the frame type and data, timestamped one second after the PMD epoch.
+func notification(m MeasureType, frameType byte, data ...byte) []byte {
+	buf := []byte{byte(m)}
+	buf = binary.LittleEndian.AppendUint64(buf, 1e9)
+	buf = append(buf, frameType)
+	return append(buf, data...)
+}
+
+// int24s returns the 24-bit little endian encoding of values.
+func int24s(values ...int32) []byte {
+	var buf []byte
+	for _, v := range values {
+		buf = append(buf, byte(v), byte(v>>8), byte(v>>16))
+	}
+	return buf
+}
+
+const ppgInterval = time.Second / time.Duration(PPGSampleFreq55)
+
+var ppgFrameTests = []struct {
+	name    string
+	freq    PPGSampleFreq
+	data    []byte
+	want    PPGFrame
+	wantErr error
+}{
+	{
+		name: "raw type 0",
+		freq: PPGSampleFreq55,
+		data: notification(PPGType, 0x00,
+			0x01, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x02, 0x00, 0x00, // 1, -1, 8388607, 2
+			0x10, 0x00, 0x00, 0x00, 0x00, 0x80, 0x03, 0x00, 0x00, 0xfe, 0xff, 0xff, // 16, -8388608, 3, -2
+		),
+		want: PPGFrame{
+			SampleFreq: PPGSampleFreq55,
+			Timestamp:  testTime,
+			FrameType:  PPGFrameType0,
+			Samples: []PPG{
+				{Timestamp: testTime.Add(-ppgInterval), Channels: []int32{1, -1, 8388607}, Ambient: 2},
+				{Timestamp: testTime, Channels: []int32{16, -8388608, 3}, Ambient: -2},
+			},
+		},
+	},
+	{
+		name: "raw type 5",
+		freq: PPGSampleFreq55,
+		data: notification(PPGType, 0x05,
+			0x01, 0x00, 0x00, 0x00, // 1
+			0x02, 0x00, 0x00, 0x80, // 0x80000002
+		),
+		want: PPGFrame{
+			SampleFreq: PPGSampleFreq55,
+			Timestamp:  testTime,
+			FrameType:  PPGFrameType5,
+			Samples: []PPG{
+				{Timestamp: testTime.Add(-ppgInterval), OperationMode: 1},
+				{Timestamp: testTime, OperationMode: 0x80000002},
+			},
+		},
+	},
+	{
+		name: "compressed type 0",
+		freq: PPGSampleFreq55,
+		data: notification(PPGType, 0x80,
+			0x64, 0x00, 0x00, 0x9c, 0xff, 0xff, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, // 100, -100, 0, 5
+			0x02, 0x01, // 1 2-bit delta
+			0x4d, // (1, -1, 0, 1)
+		),
+		want: PPGFrame{
+			SampleFreq: PPGSampleFreq55,
+			Timestamp:  testTime,
+			FrameType:  PPGFrameType0,
+			Samples: []PPG{
+				{Timestamp: testTime.Add(-ppgInterval), Channels: []int32{100, -100, 0}, Ambient: 5},
+				{Timestamp: testTime, Channels: []int32{101, -101, 0}, Ambient: 6},
+			},
+		},
+	},
+	{
+		name: "compressed type 7",
+		freq: PPGSampleFreq55,
+		data: notification(PPGType, 0x87, append(
+			int24s(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, -16, 0x20),
+			0x00, 0x01, // 1 0-bit delta
+		)...),
+		want: PPGFrame{
+			SampleFreq: PPGSampleFreq55,
+			Timestamp:  testTime,
+			FrameType:  PPGFrameType7,
+			Samples: []PPG{
+				{Timestamp: testTime.Add(-ppgInterval), Channels: []int32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, -16}, Status: 0x20},
+				{Timestamp: testTime, Channels: []int32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, -16}, Status: 0x20},
+			},
+		},
+	},
+	{
+		name: "compressed type 8",
+		freq: PPGSampleFreq55,
+		data: notification(PPGType, 0x88,
+			int24s(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, -24, 0x07)...,
+		),
+		want: PPGFrame{
+			SampleFreq: PPGSampleFreq55,
+			Timestamp:  testTime,
+			FrameType:  PPGFrameType8,
+			Samples: []PPG{
+				{Timestamp: testTime, Channels: []int32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, -24}, Status: 0x07},
+			},
+		},
+	},
+	{
+		name:    "raw type 4",
+		freq:    PPGSampleFreq55,
+		data:    notification(PPGType, 0x04, make([]byte, 36)...),
+		wantErr: errAny,
+	},
+	{
+		name:    "raw type 6",
+		freq:    PPGSampleFreq55,
+		data:    notification(PPGType, 0x06, make([]byte, 8)...),
+		wantErr: errAny,
+	},
+	{
+		name:    "compressed type 9",
+		freq:    PPGSampleFreq55,
+		data:    notification(PPGType, 0x89, make([]byte, 30)...),
+		wantErr: errAny,
+	},
+	{
+		name: "raw type 0 partial sample",
+		freq: PPGSampleFreq55,
+		data: notification(PPGType, 0x00,
+			0x01, 0x00, 0x00, 0x02, 0x00, 0x00, 0x03, 0x00, 0x00, 0x04, 0x00,
+		),
+		wantErr: errAny,
+	},
+	{
+		name:    "compressed type 7 truncated reference",
+		freq:    PPGSampleFreq55,
+		data:    notification(PPGType, 0x87, int24s(1, 2, 3, 4, 5, 6, 7, 8)...),
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "truncated header",
+		freq:    PPGSampleFreq55,
+		data:    notification(PPGType, 0x00)[:dataOffset-1],
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "wrong measurement type",
+		freq:    PPGSampleFreq55,
+		data:    notification(ECGType, 0x00, int24s(1, 2, 3, 4)...),
+		wantErr: errAny,
+	},
+	{
+		name:    "no sample frequency",
+		data:    notification(PPGType, 0x00, int24s(1, 2, 3, 4)...),
+		wantErr: errAny,
+	},
+}
+
+func TestPPGFrame(t *testing.T) {
+	for _, test := range ppgFrameTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := PPGFrame{SampleFreq: test.freq}
+			err := got.UnmarshalBinary(test.data)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+				return
+			case err != nil:
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected frame:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const ppiSampleSize = 6 // bytes
+
+// PPIHandler implements the Handler interface for PP interval data.
+// The function is called for each notification.
+type PPIHandler func([]byte)
+
+func (h PPIHandler) Handle() (Command, MeasureType, []Setting, func([]byte)) {
+	if h == nil {
+		return MeasureStop, PPIType, nil, nil
+	}
+	return MeasureStart, PPIType, nil, h
+}
+
+// PPI is a peak-to-peak interval measurement.
+type PPI struct {
+	HR               uint8 // bpm
+	Interval         time.Duration
+	Error            time.Duration
+	Blocker          bool
+	Contact          bool
+	ContactSupported bool
+}
+
+// PPIFrame is the set of PP interval measurements held in a single
+// PMD notification.
+type PPIFrame struct {
+	Timestamp time.Time
+	Samples   []PPI
+}
+
+func (m *PPIFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < dataOffset {
+		return io.ErrUnexpectedEOF
+	}
+	if MeasureType(data[sampleTypeOffset]) != PPIType {
+		return fmt.Errorf("expected sample type ppi: %v", data[sampleTypeOffset])
+	}
+	if FrameType(data[frameTypeOffset]) != PPIFrameType0 {
+		return fmt.Errorf("expected frame type ppi: %v", data[frameTypeOffset])
+	}
+
+	timestamp := binary.LittleEndian.Uint64(data[timeStampOffset:])
+
+	raw := data[dataOffset:]
+	if len(raw)%ppiSampleSize != 0 {
+		return fmt.Errorf("number of samples not a factor of %d: %v", ppiSampleSize, len(raw)%ppiSampleSize)
+	}
+	samples := make([]PPI, 0, len(raw)/ppiSampleSize)
+	for i := 0; i < len(raw); i += ppiSampleSize {
+		s := raw[i : i+ppiSampleSize]
+		// | 0x4 | 0x2 | 0x1 |
+		// | scs | cnt | blk |
+		flags := s[5]
+		samples = append(samples, PPI{
+			HR:               s[0],
+			Interval:         time.Duration(binary.LittleEndian.Uint16(s[1:])) * time.Millisecond,
+			Error:            time.Duration(binary.LittleEndian.Uint16(s[3:])) * time.Millisecond,
+			Blocker:          flags&0x1 != 0,
+			Contact:          flags&0x2 != 0,
+			ContactSupported: flags&0x4 != 0,
+		})
+	}
+
+	*m = PPIFrame{
+		Timestamp: time.Unix(int64(timestamp)/1e9+epoch, int64(timestamp)%1e9),
+		Samples:   samples,
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var ppiFrameTests = []struct {
+	name    string
+	data    []byte
+	want    PPIFrame
+	wantErr error
+}{
+	{
+		name: "flags",
+		data: notification(PPIType, 0x00,
+			0x3c, 0xe8, 0x03, 0x0a, 0x00, 0x00, // 60 bpm, 1000ms, 10ms, no flags
+			0x3d, 0xd7, 0x03, 0x14, 0x00, 0x01, // 61 bpm, 983ms, 20ms, blocker
+			0x3e, 0xc8, 0x03, 0x05, 0x00, 0x06, // 62 bpm, 968ms, 5ms, contact supported and made
+			0xff, 0xff, 0xff, 0xff, 0xff, 0x07, // 255 bpm, 65535ms, 65535ms, all flags
+		),
+		want: PPIFrame{
+			Timestamp: testTime,
+			Samples: []PPI{
+				{HR: 60, Interval: 1000 * time.Millisecond, Error: 10 * time.Millisecond},
+				{HR: 61, Interval: 983 * time.Millisecond, Error: 20 * time.Millisecond, Blocker: true},
+				{HR: 62, Interval: 968 * time.Millisecond, Error: 5 * time.Millisecond, Contact: true, ContactSupported: true},
+				{HR: 255, Interval: 65535 * time.Millisecond, Error: 65535 * time.Millisecond, Blocker: true, Contact: true, ContactSupported: true},
+			},
+		},
+	},
+	{
+		name: "contact supported without contact",
+		data: notification(PPIType, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x04, // 0 bpm, 0ms, 0ms, contact supported
+		),
+		want: PPIFrame{
+			Timestamp: testTime,
+			Samples:   []PPI{{ContactSupported: true}},
+		},
+	},
+	{
+		name: "empty",
+		data: notification(PPIType, 0x00),
+		want: PPIFrame{
+			Timestamp: testTime,
+			Samples:   []PPI{},
+		},
+	},
+	{
+		name: "partial sample",
+		data: notification(PPIType, 0x00,
+			0x3c, 0xe8, 0x03, 0x0a, 0x00, 0x00,
+			0x3d, 0xd7, 0x03,
+		),
+		wantErr: errAny,
+	},
+	{
+		name:    "truncated header",
+		data:    notification(PPIType, 0x00)[:dataOffset-1],
+		wantErr: io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "wrong measurement type",
+		data:    notification(PPGType, 0x00, 0x3c, 0xe8, 0x03, 0x0a, 0x00, 0x00),
+		wantErr: errAny,
+	},
+	{
+		name:    "wrong frame type",
+		data:    notification(PPIType, 0x80, 0x3c, 0xe8, 0x03, 0x0a, 0x00, 0x00),
+		wantErr: errAny,
+	},
+}
+
+func TestPPIFrame(t *testing.T) {
+	for _, test := range ppiFrameTests {
+		t.Run(test.name, func(t *testing.T) {
+			var got PPIFrame
+			err := got.UnmarshalBinary(test.data)
+			switch {
+			case test.wantErr == errAny:
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			case !errors.Is(err, test.wantErr):
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+				return
+			case err != nil:
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected frame:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
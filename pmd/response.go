@@ -0,0 +1,104 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// controlPointResponse is the first byte of a control point response.
+const controlPointResponse = 0xf0
+
+// ControlPointResponse is a response from the PMD control point.
+type ControlPointResponse struct {
+	// Command is the command that the response is for.
+	Command Command
+	// Record and Measure are the recording and
+	// measurement types the command was for.
+	Record  RecordingType
+	Measure MeasureType
+	// Status is the result of the command.
+	Status Status
+	// More indicates that the parameters continue
+	// in a following notification. Responses
+	// returned by Listener methods have been
+	// reassembled from all their notifications,
+	// so More is false.
+	More bool
+	// Params holds any parameters returned with
+	// the response.
+	Params []byte
+}
+
+func (r *ControlPointResponse) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("short response: %#x", data)
+	}
+	if data[0] != controlPointResponse {
+		return fmt.Errorf("invalid response: %#x", data)
+	}
+	resp := ControlPointResponse{
+		Command: Command(data[1]),
+		Record:  RecordingType(data[2] >> 7),
+		Measure: MeasureType(data[2] &^ 0x80),
+		Status:  Status(data[3]),
+	}
+	if len(data) > 4 {
+		resp.More = data[4] != 0
+	}
+	if len(data) > 5 {
+		resp.Params = bytes.Clone(data[5:])
+	}
+	*r = resp
+	return nil
+}
+
+// Err returns the response status as an error if the command was not
+// successful, and nil otherwise.
+func (r ControlPointResponse) Err() error {
+	if r.Status == Success {
+		return nil
+	}
+	return r.Status
+}
+
+// Settings returns the settings held in the response parameters, such
+// as the available settings in response to a MeasureSettings command
+// or the conversion factor in response to a MeasureStart command.
+func (r ControlPointResponse) Settings() ([]Setting, error) {
+	return parseSetting(r.Params)
+}
+
+// Status is a PMD control point response status.
+type Status uint8
+
+//go:generate go tool golang.org/x/tools/cmd/stringer -type Status
+const (
+	Success                 Status = 0
+	InvalidOpCode           Status = 1
+	InvalidMeasurementType  Status = 2
+	NotSupported            Status = 3
+	InvalidLength           Status = 4
+	InvalidParameter        Status = 5
+	AlreadyInState          Status = 6
+	InvalidResolution       Status = 7
+	InvalidSampleRate       Status = 8
+	InvalidRange            Status = 9
+	InvalidMTU              Status = 10
+	InvalidNumberOfChannels Status = 11
+	InvalidState            Status = 12
+	DeviceInCharger         Status = 13
+)
+
+// Error returns the description of a failed command status. A Success
+// status is not an error and is described by its name alone, so that
+// formatted responses do not report successful commands as failures.
+func (s Status) Error() string {
+	if s == Success {
+		return s.String()
+	}
+	return "control point error: " + s.String()
+}
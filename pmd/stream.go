@@ -0,0 +1,165 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"iter"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy is the behaviour of a Stream when a notification arrives while
+// its queue is full.
+type Policy uint8
+
+const (
+	// Block waits for space in the queue. This
+	// stalls the delivery of all notifications
+	// from the sensor until the consumer catches
+	// up.
+	Block Policy = iota
+	// DropOldest discards the oldest queued
+	// notification to make space.
+	DropOldest
+	// DropNewest discards the arriving
+	// notification.
+	DropNewest
+)
+
+// Stream is a bounded queue of PMD notifications that are decoded by
+// the consumer rather than on the Bluetooth notification goroutine, so
+// that slow consumers do not stall notification delivery.
+//
+// A Stream's Push method is used as the notification function of a
+// Handler, for example
+//
+//	s := pmd.NewStream(64, pmd.DropOldest, pmd.Decoder(pmd.AccFrame{SampleFreq: 52}))
+//	_, err := l.SetHandler(ctx, pmd.AccHandler{SampleFreq: 52, Range: 8, Handler: s.Push})
+//
+// and the decoded frames are consumed with All.
+type Stream[T any] struct {
+	decode func([]byte) (T, error)
+	policy Policy
+
+	queue   chan []byte
+	done    chan struct{}
+	close   sync.Once
+	dropped atomic.Uint64
+}
+
+// NewStream returns a new Stream that holds up to size notifications,
+// handling a full queue according to policy. The decode function is
+// called by the consumer to decode each notification.
+func NewStream[T any](size int, policy Policy, decode func([]byte) (T, error)) *Stream[T] {
+	return &Stream[T]{
+		decode: decode,
+		policy: policy,
+		queue:  make(chan []byte, size),
+		done:   make(chan struct{}),
+	}
+}
+
+// Decoder returns a decode function for use with NewStream that
+// decodes notifications into a copy of proto. Configuration fields of
+// proto, such as the sample frequency of a frame, are retained.
+func Decoder[T any, P interface {
+	*T
+	encoding.BinaryUnmarshaler
+}](proto T) func([]byte) (T, error) {
+	return func(buf []byte) (T, error) {
+		v := proto
+		err := P(&v).UnmarshalBinary(buf)
+		return v, err
+	}
+}
+
+// Push adds a copy of the notification in buf to the queue. It is
+// intended to be used as a Handler notification function. Notifications
+// pushed after the Stream has been closed are discarded.
+func (s *Stream[T]) Push(buf []byte) {
+	// Check for closure first since the selects
+	// below choose randomly between ready cases.
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	buf = bytes.Clone(buf)
+	switch s.policy {
+	case DropOldest:
+		for {
+			select {
+			case <-s.done:
+				return
+			case s.queue <- buf:
+				return
+			default:
+			}
+			select {
+			case <-s.queue:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case DropNewest:
+		select {
+		case <-s.done:
+		case s.queue <- buf:
+		default:
+			s.dropped.Add(1)
+		}
+	default:
+		select {
+		case <-s.done:
+		case s.queue <- buf:
+		}
+	}
+}
+
+// All returns an iterator over the decoded notifications in the queue.
+// Decoding errors are yielded with the zero value of T and iteration
+// continues. Iteration ends when the Stream is closed and the queue has
+// been drained, or when ctx is cancelled, in which case the context's
+// error is yielded.
+func (s *Stream[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			var buf []byte
+			select {
+			case <-ctx.Done():
+				var zero T
+				yield(zero, ctx.Err())
+				return
+			case buf = <-s.queue:
+			case <-s.done:
+				select {
+				case buf = <-s.queue:
+				default:
+					return
+				}
+			}
+			v, err := s.decode(buf)
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// Dropped returns the number of notifications discarded because the
+// queue was full.
+func (s *Stream[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close closes the Stream. Notifications already in the queue remain
+// available to All. Close does not stop the sensor stream; that is
+// done by setting a stopping Handler with Listener.SetHandler.
+func (s *Stream[T]) Close() {
+	s.close.Do(func() { close(s.done) })
+}
@@ -0,0 +1,187 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// decodeFirst returns the first byte of buf.
+func decodeFirst(buf []byte) (byte, error) {
+	if len(buf) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return buf[0], nil
+}
+
+var streamPolicyTests = []struct {
+	name        string
+	policy      Policy
+	size        int
+	push        []byte
+	want        []byte
+	wantDropped uint64
+}{
+	{
+		name:   "drop oldest",
+		policy: DropOldest,
+		size:   2,
+		push:   []byte{1, 2, 3, 4, 5},
+		want:   []byte{4, 5},
+
+		wantDropped: 3,
+	},
+	{
+		name:   "drop newest",
+		policy: DropNewest,
+		size:   2,
+		push:   []byte{1, 2, 3, 4, 5},
+		want:   []byte{1, 2},
+
+		wantDropped: 3,
+	},
+	{
+		name:   "not full",
+		policy: DropNewest,
+		size:   4,
+		push:   []byte{1, 2, 3},
+		want:   []byte{1, 2, 3},
+	},
+}
+
+func TestStreamPolicy(t *testing.T) {
+	for _, test := range streamPolicyTests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewStream(test.size, test.policy, decodeFirst)
+			for _, b := range test.push {
+				s.Push([]byte{b})
+			}
+			// Notifications queued before Close are
+			// drained by All.
+			s.Close()
+			s.Push([]byte{0})
+			var got []byte
+			for v, err := range s.All(context.Background()) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got = append(got, v)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("unexpected notifications: got:%v want:%v", got, test.want)
+			}
+			if n := s.Dropped(); n != test.wantDropped {
+				t.Errorf("unexpected number of dropped notifications: got:%d want:%d", n, test.wantDropped)
+			}
+		})
+	}
+}
+
+func TestStreamBlock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := NewStream(1, Block, decodeFirst)
+	s.Push([]byte{1})
+	pushed := make(chan struct{})
+	go func() {
+		s.Push([]byte{2})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push to full queue did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var got []byte
+	for v, err := range s.All(ctx) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, v)
+		if len(got) == 2 {
+			break
+		}
+	}
+	<-pushed
+	if want := []byte{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("unexpected notifications: got:%v want:%v", got, want)
+	}
+	if n := s.Dropped(); n != 0 {
+		t.Errorf("unexpected number of dropped notifications: got:%d want:0", n)
+	}
+
+	// A blocked push is released by Close.
+	s.Push([]byte{3})
+	pushed = make(chan struct{})
+	go func() {
+		s.Push([]byte{4})
+		close(pushed)
+	}()
+	s.Close()
+	select {
+	case <-ctx.Done():
+		t.Fatal("push to full queue not released by close")
+	case <-pushed:
+	}
+}
+
+func TestStreamAll(t *testing.T) {
+	s := NewStream(4, DropNewest, decodeFirst)
+	buf := []byte{1}
+	s.Push(buf)
+	buf[0] = 2 // Pushed notifications are copied.
+	s.Push(nil)
+	s.Push(buf)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		got  []byte
+		errs []error
+	)
+	for v, err := range s.All(ctx) {
+		got = append(got, v)
+		errs = append(errs, err)
+		if len(got) == 3 {
+			cancel()
+		}
+	}
+	if want := []byte{1, 0, 2, 0}; !slices.Equal(got, want) {
+		t.Errorf("unexpected notifications: got:%v want:%v", got, want)
+	}
+	wantErrs := []error{nil, io.ErrUnexpectedEOF, nil, context.Canceled}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("unexpected number of errors: got:%d want:%d", len(errs), len(wantErrs))
+	}
+	for i, err := range errs {
+		if !errors.Is(err, wantErrs[i]) {
+			t.Errorf("unexpected error for notification %d: got:%v want:%v", i, err, wantErrs[i])
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	s := NewStream(1, DropNewest, Decoder(PPGFrame{SampleFreq: PPGSampleFreq55}))
+	s.Push(notification(PPGType, 0x05, 0x01, 0x00, 0x00, 0x00))
+	s.Close()
+	for m, err := range s.All(context.Background()) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m.SampleFreq != PPGSampleFreq55 {
+			t.Errorf("sample frequency not retained: got:%d want:%d", m.SampleFreq, PPGSampleFreq55)
+		}
+		if len(m.Samples) != 1 || m.Samples[0].OperationMode != 1 {
+			t.Errorf("unexpected samples: %+v", m.Samples)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Subscription is a subscription to a measurement stream made with
+// Listener.Subscribe.
+type Subscription struct {
+	l       *Listener
+	measure MeasureType
+	handle  func([]byte)
+}
+
+// Subscribe adds a subscriber to the measurement stream configured by
+// h. The function returned by h.Handle is called with the data for all
+// notifications of the measurement type until the subscription is
+// cancelled with Unsubscribe.
+//
+// The stream is started with the settings returned by h.Handle when
+// there is no running stream of the measurement type. Otherwise the
+// settings must match those of the running stream and no command is
+// sent. The returned ControlPointResponse is the response to the
+// command that started the stream, and holds the stream's conversion
+// factor.
+func (l *Listener) Subscribe(ctx context.Context, h Handler) (*Subscription, ControlPointResponse, error) {
+	com, measureTyp, settings, handle := h.Handle()
+	if int(measureTyp) >= len(l.handlers) {
+		return nil, ControlPointResponse{}, fmt.Errorf("invalid measurement type: %d", measureTyp)
+	}
+	if com != MeasureStart || handle == nil {
+		return nil, ControlPointResponse{}, fmt.Errorf("subscription handler does not start measurement type %d", measureTyp)
+	}
+	sub := &Subscription{l: l, measure: measureTyp, handle: handle}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.started[measureTyp] {
+		err := l.checkSettings(measureTyp, settings)
+		if err != nil {
+			return nil, ControlPointResponse{}, err
+		}
+		l.addSub(sub)
+		return sub, l.resp[measureTyp], nil
+	}
+	// The subscription is added before the command
+	// is sent since data may arrive before the
+	// response.
+	l.addSub(sub)
+	resp, err := sendCommand(ctx, l.cp, MeasureStart, Online, measureTyp, settings...)
+	if err != nil {
+		l.removeSub(sub)
+		return nil, resp, err
+	}
+	l.started[measureTyp] = true
+	l.settings[measureTyp] = settings
+	l.resp[measureTyp] = resp
+	return sub, resp, nil
+}
+
+// Unsubscribe cancels the subscription. If it is the last subscriber
+// to the measurement stream and no handler has been set for the
+// measurement type with SetHandler, the stream is stopped. Calling
+// Unsubscribe on a cancelled subscription is a no-op.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	l := s.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.removeSub(s) {
+		return nil
+	}
+	l.hmu.RLock()
+	idle := len(l.subs[s.measure]) == 0 && l.handlers[s.measure] == nil
+	l.hmu.RUnlock()
+	if !idle || !l.started[s.measure] {
+		return nil
+	}
+	_, err := sendCommand(ctx, l.cp, MeasureStop, Online, s.measure)
+	if err != nil {
+		return err
+	}
+	l.started[s.measure] = false
+	l.settings[s.measure] = nil
+	l.resp[s.measure] = ControlPointResponse{}
+	return nil
+}
+
+// subscribed returns whether the measurement type has subscribers.
+func (l *Listener) subscribed(m MeasureType) bool {
+	l.hmu.RLock()
+	defer l.hmu.RUnlock()
+	return len(l.subs[m]) != 0
+}
+
+// addSub adds sub to the subscribers of its measurement type.
+func (l *Listener) addSub(sub *Subscription) {
+	l.hmu.Lock()
+	defer l.hmu.Unlock()
+	l.subs[sub.measure] = append(slices.Clip(l.subs[sub.measure]), sub)
+}
+
+// removeSub removes sub from the subscribers of its measurement type
+// and returns whether it was found.
+func (l *Listener) removeSub(sub *Subscription) bool {
+	l.hmu.Lock()
+	defer l.hmu.Unlock()
+	subs := l.subs[sub.measure]
+	i := slices.Index(subs, sub)
+	if i < 0 {
+		return false
+	}
+	l.subs[sub.measure] = slices.Delete(slices.Clone(subs), i, i+1)
+	return true
+}
+
+// checkSettings returns an error if settings do not match the settings
+// of the running stream of the measurement type.
+func (l *Listener) checkSettings(m MeasureType, settings []Setting) error {
+	got, err := encodeSettings(settings...)
+	if err != nil {
+		return err
+	}
+	want, err := encodeSettings(l.settings[m]...)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("measurement type %d already running with different settings", m)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"context"
+	"fmt"
+)
+
+// TriggerMode is the event that starts offline recordings
+// automatically.
+type TriggerMode uint8
+
+const (
+	TriggerDisabled      TriggerMode = 0
+	TriggerSystemStart   TriggerMode = 1
+	TriggerExerciseStart TriggerMode = 2
+)
+
+// Trigger is the offline recording trigger configuration for a
+// measurement type.
+type Trigger struct {
+	Measure MeasureType
+	// Enabled indicates that recording of the
+	// measurement type is started by the trigger.
+	Enabled bool
+	// Settings holds the recording settings used
+	// when the trigger starts a recording.
+	Settings []Setting
+}
+
+// TriggerStatus is the offline recording trigger configuration of a
+// sensor.
+type TriggerStatus struct {
+	Mode     TriggerMode
+	Triggers []Trigger
+}
+
+func (s *TriggerStatus) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("missing trigger mode")
+	}
+	status := TriggerStatus{Mode: TriggerMode(data[0])}
+	data = data[1:]
+	for len(data) != 0 {
+		// | 0    | 1    | 2   | 3...     |
+		// | stat | type | len | settings |
+		//
+		// The length and settings are only
+		// present for enabled triggers.
+		if len(data) < 2 {
+			return fmt.Errorf("short trigger: %#x", data)
+		}
+		t := Trigger{Enabled: data[0] != 0, Measure: MeasureType(data[1])}
+		data = data[2:]
+		if t.Enabled {
+			if len(data) == 0 || len(data) < 1+int(data[0]) {
+				return fmt.Errorf("short trigger settings: %#x", data)
+			}
+			n := int(data[0])
+			var err error
+			t.Settings, err = parseSetting(data[1 : 1+n])
+			if err != nil {
+				return fmt.Errorf("invalid trigger settings: %w", err)
+			}
+			data = data[1+n:]
+		}
+		status.Triggers = append(status.Triggers, t)
+	}
+	*s = status
+	return nil
+}
+
+// OfflineTriggers returns the offline recording trigger configuration
+// of the sensor the Listener is connected to.
+func (l *Listener) OfflineTriggers(ctx context.Context) (TriggerStatus, error) {
+	resp, err := sendOp(ctx, l.cp, OfflineTriggerStatus)
+	if err != nil {
+		return TriggerStatus{}, err
+	}
+	var status TriggerStatus
+	err = status.UnmarshalBinary(resp.Params)
+	return status, err
+}
+
+// SetOfflineTriggerMode sets the event that starts offline recordings
+// of the measurement types enabled with SetOfflineTrigger.
+func (l *Listener) SetOfflineTriggerMode(ctx context.Context, mode TriggerMode) error {
+	_, err := sendOp(ctx, l.cp, OfflineTriggerMode, byte(mode))
+	return err
+}
+
+// SetOfflineTrigger enables or disables the automatic start of offline
+// recordings of the measurement type. The settings are used for
+// recordings started by the trigger and are ignored when disabling the
+// trigger.
+func (l *Listener) SetOfflineTrigger(ctx context.Context, m MeasureType, enable bool, settings ...Setting) error {
+	if int(m) >= len(l.handlers) {
+		return fmt.Errorf("invalid measurement type: %d", m)
+	}
+	if !enable {
+		_, err := sendOp(ctx, l.cp, OfflineTriggerSettings, 0, byte(m))
+		return err
+	}
+	enc, err := encodeSettings(settings...)
+	if err != nil {
+		return err
+	}
+	_, err = sendOp(ctx, l.cp, OfflineTriggerSettings, append([]byte{1, byte(m)}, enc...)...)
+	return err
+}
@@ -0,0 +1,105 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+var triggerStatusTests = []struct {
+	name    string
+	data    []byte
+	want    TriggerStatus
+	wantErr bool
+}{
+	{
+		name: "mode only",
+		data: []byte{byte(TriggerExerciseStart)},
+		want: TriggerStatus{Mode: TriggerExerciseStart},
+	},
+	{
+		name: "mixed",
+		data: []byte{
+			byte(TriggerSystemStart),
+			0x00, byte(PPGType), // disabled
+			0x01, byte(AccType), 0x08, // enabled, 8 bytes of settings
+			0x00, 0x01, 0x34, 0x00, // 52 Hz
+			0x01, 0x01, 0x10, 0x00, // 16 bits
+			0x01, byte(PPIType), 0x00, // enabled without settings
+			0x00, byte(GyroType), // disabled
+		},
+		want: TriggerStatus{
+			Mode: TriggerSystemStart,
+			Triggers: []Trigger{
+				{Measure: PPGType},
+				{Measure: AccType, Enabled: true, Settings: []Setting{
+					Uint16{Type: SampleRateSetting, Val: []uint16{52}},
+					Uint16{Type: ResolutionSetting, Val: []uint16{16}},
+				}},
+				{Measure: PPIType, Enabled: true},
+				{Measure: GyroType},
+			},
+		},
+	},
+	{
+		name:    "empty",
+		data:    nil,
+		wantErr: true,
+	},
+	{
+		name: "short trigger",
+		data: []byte{
+			byte(TriggerSystemStart),
+			0x00, byte(PPGType),
+			0x00,
+		},
+		wantErr: true,
+	},
+	{
+		name: "missing settings length",
+		data: []byte{
+			byte(TriggerSystemStart),
+			0x01, byte(AccType),
+		},
+		wantErr: true,
+	},
+	{
+		name: "truncated settings",
+		data: []byte{
+			byte(TriggerSystemStart),
+			0x01, byte(AccType), 0x08,
+			0x00, 0x01, 0x34, 0x00,
+		},
+		wantErr: true,
+	},
+	{
+		name: "invalid settings",
+		data: []byte{
+			byte(TriggerSystemStart),
+			0x01, byte(AccType), 0x04,
+			0xff, 0x01, 0x34, 0x00,
+		},
+		wantErr: true,
+	},
+}
+
+func TestTriggerStatus(t *testing.T) {
+	for _, test := range triggerStatusTests {
+		t.Run(test.name, func(t *testing.T) {
+			var got TriggerStatus
+			err := got.UnmarshalBinary(test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: got:%v want error:%t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected trigger status:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,531 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package reconnect implements supervision of sensor connections,
+// reconnecting and resuming streams when a connection is lost.
+//
+// A Supervisor is a gatt.Device that delegates to a connection
+// obtained from a dial function. Characteristics returned by the
+// Supervisor remain valid across reconnections: after a new connection
+// is made, they are rediscovered and their notification callbacks are
+// re-registered, so listeners such as heart.RateListener and
+// pmd.Listener constructed with the Supervisor continue to receive
+// notifications. Registered Resumers, such as pmd.Listener, are then
+// called to restart sensor streams.
+//
+// A connection is considered lost when Lost is called, or when the
+// characteristics marked with Watch stop delivering notifications
+// while their streams are running.
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+)
+
+// Default supervision parameters.
+const (
+	DefaultTimeout    = 5 * time.Second
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+)
+
+// ErrDisconnected is returned by operations on a Supervisor or its
+// characteristics while the connection is lost.
+var ErrDisconnected = errors.New("reconnect: device disconnected")
+
+// Config holds the parameters for a Supervisor.
+type Config struct {
+	// Timeout is the duration without notifications
+	// from watched characteristics with running
+	// streams after which the connection is considered
+	// lost; see Supervisor.Watch. If Timeout is zero,
+	// DefaultTimeout is used.
+	//
+	// The timeout is a fallback for connections that
+	// fail silently. Supervisor.Lost should be used to
+	// signal disconnections reported by the adapter.
+	Timeout time.Duration
+
+	// MinBackoff and MaxBackoff are the bounds of the
+	// delay between reconnection attempts. The delay
+	// starts at MinBackoff and doubles after each failed
+	// attempt up to MaxBackoff. If zero, DefaultMinBackoff
+	// and DefaultMaxBackoff are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Report, if not nil, is called with each outage
+	// after the connection has been restored.
+	Report func(Outage)
+}
+
+// Outage describes a period during which the connection was lost.
+// Data from the sensor is missing between Lost and Restored.
+type Outage struct {
+	// Lost is the time of the last activity seen on
+	// the lost connection.
+	Lost time.Time
+	// Restored is the time streams were resumed.
+	Restored time.Time
+	// Attempts is the number of connection attempts
+	// made to restore the connection.
+	Attempts int
+	// Err is the last error encountered while
+	// reconnecting, if any.
+	Err error
+}
+
+// Resumer is a stream consumer that restarts its streams after a
+// reconnection.
+type Resumer interface {
+	// Resume is called after the connection has been
+	// re-established and characteristics have been
+	// rediscovered.
+	Resume(ctx context.Context) error
+}
+
+// Supervisor is a gatt.Device that reconnects to a sensor when the
+// connection is lost.
+type Supervisor struct {
+	dial func(context.Context) (gatt.Device, error)
+	cfg  Config
+
+	mu       sync.Mutex
+	dev      gatt.Device
+	chars    map[[2]bluetooth.UUID]*characteristic
+	watched  map[[2]bluetooth.UUID]func() bool
+	resumers []Resumer
+
+	// last is the time of the last activity in
+	// nanoseconds since the Unix epoch.
+	last atomic.Int64
+
+	lost      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a new Supervisor connected to a device obtained by
+// calling dial. The dial function is called again to reconnect when
+// the connection is lost while Run is executing.
+func New(ctx context.Context, dial func(context.Context) (gatt.Device, error), cfg Config) (*Supervisor, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		return nil, fmt.Errorf("reconnect: maximum backoff less than minimum: %v < %v", cfg.MaxBackoff, cfg.MinBackoff)
+	}
+	dev, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Supervisor{
+		dial:    dial,
+		cfg:     cfg,
+		dev:     dev,
+		chars:   make(map[[2]bluetooth.UUID]*characteristic),
+		watched: make(map[[2]bluetooth.UUID]func() bool),
+		lost:    make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	s.touch()
+	return s, nil
+}
+
+// Register adds r to the set of Resumers called after a reconnection.
+// Resumers are called in the order they were registered.
+func (s *Supervisor) Register(r Resumer) {
+	s.mu.Lock()
+	s.resumers = append(s.resumers, r)
+	s.mu.Unlock()
+}
+
+// Watch marks the specified characteristic of the specified service as
+// a data-bearing characteristic whose notifications indicate that the
+// connection is alive, such as a heart rate measurement or PMD data
+// characteristic. Characteristics that notify only in response to
+// commands or changes, such as a control point or battery level, should
+// not be watched.
+//
+// A watched characteristic is expected to deliver notifications while
+// it has a notification callback registered and streaming, if not nil,
+// returns true; streaming should report whether the streams carried by
+// the characteristic are running, as pmd.Listener.Streaming does. The
+// connection is considered lost when no expected characteristic has
+// delivered a notification within the Timeout of its last notification
+// or of becoming expected, including after a reconnection.
+func (s *Supervisor) Watch(srvID, charID bluetooth.UUID, streaming func() bool) {
+	if streaming == nil {
+		streaming = func() bool { return true }
+	}
+	s.mu.Lock()
+	s.watched[[2]bluetooth.UUID{srvID, charID}] = streaming
+	s.mu.Unlock()
+}
+
+// Characteristic returns the specified characteristic of the specified
+// service. The returned characteristic is rediscovered after each
+// reconnection.
+func (s *Supervisor) Characteristic(srvID, charID bluetooth.UUID) (gatt.Characteristic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dev == nil {
+		return nil, ErrDisconnected
+	}
+	key := [2]bluetooth.UUID{srvID, charID}
+	if c, ok := s.chars[key]; ok {
+		return c, nil
+	}
+	char, err := s.dev.Characteristic(srvID, charID)
+	if err != nil {
+		return nil, err
+	}
+	c := &characteristic{s: s, service: srvID, id: charID, char: char}
+	s.chars[key] = c
+	return c, nil
+}
+
+// Lost signals that the connection has been lost. It may be called,
+// for example, from a bluetooth.Adapter connect handler to trigger a
+// reconnection without waiting for the inactivity timeout.
+func (s *Supervisor) Lost() {
+	select {
+	case s.lost <- struct{}{}:
+	default:
+	}
+}
+
+// Disconnect stops supervision and disconnects the current connection.
+func (s *Supervisor) Disconnect() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	s.mu.Lock()
+	dev := s.dev
+	s.dev = nil
+	s.mu.Unlock()
+	for _, c := range s.characteristics() {
+		c.unbind()
+	}
+	if dev == nil {
+		return nil
+	}
+	return dev.Disconnect()
+}
+
+// Run supervises the connection, reconnecting when it is lost, until
+// ctx is cancelled or the Supervisor is disconnected. It returns nil
+// if the Supervisor was disconnected and ctx.Err() otherwise.
+func (s *Supervisor) Run(ctx context.Context) error {
+	tick := time.NewTicker(s.cfg.Timeout / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return nil
+		case <-s.lost:
+		case <-tick.C:
+			if !s.inactive() {
+				continue
+			}
+		}
+		err := s.reconnect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reconnect drops the current connection and dials until a new
+// connection is made and all Resumers have been resumed.
+func (s *Supervisor) reconnect(ctx context.Context) error {
+	o := Outage{Lost: s.lastActivity()}
+
+	s.mu.Lock()
+	dev := s.dev
+	s.dev = nil
+	s.mu.Unlock()
+	for _, c := range s.characteristics() {
+		c.unbind()
+	}
+	if dev != nil {
+		dev.Disconnect()
+	}
+
+	delay := s.cfg.MinBackoff
+	for {
+		o.Attempts++
+		err := s.connect(ctx)
+		if err == nil {
+			break
+		}
+		o.Err = err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, s.cfg.MaxBackoff)
+	}
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	o.Restored = time.Now()
+
+	// Drop any loss signal raised by the old connection.
+	select {
+	case <-s.lost:
+	default:
+	}
+	if s.cfg.Report != nil {
+		s.cfg.Report(o)
+	}
+	return nil
+}
+
+// connect dials a new connection, rebinds the characteristics and
+// resumes the registered Resumers.
+func (s *Supervisor) connect(ctx context.Context) error {
+	dev, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range s.characteristics() {
+		err = c.bind(dev)
+		if err != nil {
+			s.drop(dev)
+			return err
+		}
+	}
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		s.drop(dev)
+		return nil
+	default:
+	}
+	s.dev = dev
+	resumers := slices.Clone(s.resumers)
+	s.mu.Unlock()
+	s.touch()
+
+	for _, r := range resumers {
+		err = r.Resume(ctx)
+		if err != nil {
+			s.mu.Lock()
+			s.dev = nil
+			s.mu.Unlock()
+			s.drop(dev)
+			return err
+		}
+	}
+	return nil
+}
+
+// drop unbinds all characteristics and disconnects dev.
+func (s *Supervisor) drop(dev gatt.Device) {
+	for _, c := range s.characteristics() {
+		c.unbind()
+	}
+	dev.Disconnect()
+}
+
+// characteristics returns the characteristics that have been
+// returned by the Supervisor.
+func (s *Supervisor) characteristics() []*characteristic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chars := make([]*characteristic, 0, len(s.chars))
+	for _, c := range s.chars {
+		chars = append(chars, c)
+	}
+	return chars
+}
+
+// inactive returns whether the watched characteristics that are
+// expected to deliver notifications have all been silent for longer
+// than the timeout. It returns false if no watched characteristic is
+// expected to deliver notifications. Characteristics that are not
+// expected are disarmed and are armed when they become expected, so
+// the timeout starts when their streams are started.
+func (s *Supervisor) inactive() bool {
+	type watch struct {
+		c         *characteristic
+		streaming func() bool
+	}
+	s.mu.Lock()
+	var watched []watch
+	for key, c := range s.chars {
+		if streaming, ok := s.watched[key]; ok {
+			watched = append(watched, watch{c, streaming})
+		}
+	}
+	s.mu.Unlock()
+	now := time.Now()
+	var (
+		expected bool
+		last     time.Time
+	)
+	for _, w := range watched {
+		// The streaming function is called without
+		// holding the characteristic's lock since it
+		// may be provided by a notification consumer.
+		streaming := w.streaming()
+		c := w.c
+		c.mu.Lock()
+		switch {
+		case c.char == nil, c.callback == nil, !streaming:
+			c.last = time.Time{}
+		case c.last.IsZero():
+			c.last = now
+			fallthrough
+		default:
+			expected = true
+			if c.last.After(last) {
+				last = c.last
+			}
+		}
+		c.mu.Unlock()
+	}
+	return expected && now.Sub(last) >= s.cfg.Timeout
+}
+
+func (s *Supervisor) touch() {
+	s.last.Store(time.Now().UnixNano())
+}
+
+func (s *Supervisor) lastActivity() time.Time {
+	return time.Unix(0, s.last.Load())
+}
+
+// characteristic is a characteristic that is rebound to a new
+// connection after reconnection.
+type characteristic struct {
+	s           *Supervisor
+	service, id bluetooth.UUID
+
+	mu       sync.Mutex
+	char     gatt.Characteristic // nil while disconnected.
+	callback func([]byte)
+
+	// last is the time of the last notification
+	// or of the characteristic becoming expected
+	// to deliver notifications if it has not yet
+	// done so. It is zero if the characteristic
+	// is not expected to deliver notifications.
+	last time.Time
+}
+
+// bind discovers the characteristic on dev and re-enables
+// notifications if a callback has been registered.
+func (c *characteristic) bind(dev gatt.Device) error {
+	char, err := dev.Characteristic(c.service, c.id)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.last = time.Time{}
+	callback := c.callback
+	c.mu.Unlock()
+	if callback != nil {
+		err = char.EnableNotifications(c.notify)
+		if err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	c.char = char
+	c.mu.Unlock()
+	return nil
+}
+
+// unbind detaches the characteristic from its connection.
+func (c *characteristic) unbind() {
+	c.mu.Lock()
+	c.char = nil
+	c.last = time.Time{}
+	c.mu.Unlock()
+}
+
+func (c *characteristic) current() (gatt.Characteristic, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.char == nil {
+		return nil, ErrDisconnected
+	}
+	return c.char, nil
+}
+
+func (c *characteristic) Read(data []byte) (int, error) {
+	char, err := c.current()
+	if err != nil {
+		return 0, err
+	}
+	return char.Read(data)
+}
+
+func (c *characteristic) WriteWithoutResponse(p []byte) (int, error) {
+	char, err := c.current()
+	if err != nil {
+		return 0, err
+	}
+	return char.WriteWithoutResponse(p)
+}
+
+// EnableNotifications registers callback with the characteristic. If
+// the connection is lost, the callback is retained and registered with
+// the characteristic of the next connection.
+func (c *characteristic) EnableNotifications(callback func(buf []byte)) error {
+	c.mu.Lock()
+	c.callback = callback
+	char := c.char
+	c.mu.Unlock()
+	if char == nil {
+		return nil
+	}
+	if callback == nil {
+		return char.EnableNotifications(nil)
+	}
+	return char.EnableNotifications(c.notify)
+}
+
+func (c *characteristic) notify(buf []byte) {
+	c.s.touch()
+	c.mu.Lock()
+	c.last = time.Now()
+	callback := c.callback
+	c.mu.Unlock()
+	if callback != nil {
+		callback(buf)
+	}
+}
+
+func (c *characteristic) GetMTU() (uint16, error) {
+	char, err := c.current()
+	if err != nil {
+		return 0, err
+	}
+	return char.GetMTU()
+}
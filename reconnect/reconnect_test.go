@@ -0,0 +1,176 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reconnect_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/pmd"
+	"github.com/kortschak/polar/reconnect"
+	"github.com/kortschak/polar/sim"
+)
+
+func TestSupervisor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The first connection is dropped by the test, and the
+	// second fails silently after reconnection by never
+	// delivering PMD data.
+	var (
+		mu      sync.Mutex
+		sensors []*sim.Sensor
+	)
+	dial := func(context.Context) (gatt.Device, error) {
+		s, err := sim.New(sim.H10, 60)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		sensors = append(sensors, s)
+		n := len(sensors)
+		mu.Unlock()
+		if n == 2 {
+			return mutedDevice{s}, nil
+		}
+		return s, nil
+	}
+
+	// ECG frames are notified about twice a second.
+	const timeout = time.Second
+	outages := make(chan reconnect.Outage, 3)
+	s, err := reconnect.New(ctx, dial, reconnect.Config{
+		Timeout:    timeout,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+		Report:     func(o reconnect.Outage) { outages <- o },
+	})
+	if err != nil {
+		t.Fatalf("failed to create supervisor: %v", err)
+	}
+	l, err := pmd.NewListener(s)
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	s.Register(l)
+	s.Watch(pmdService, pmdData, l.Streaming)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	defer func() {
+		l.Close()
+		err := <-done
+		if err != nil {
+			t.Errorf("unexpected error from run: %v", err)
+		}
+	}()
+
+	frames := make(chan []byte, 1)
+	handler := pmd.ECGHandler(func(buf []byte) {
+		select {
+		case frames <- buf:
+		default:
+		}
+	})
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no ecg notification %s", what)
+		case <-frames:
+		}
+	}
+	_, err = l.SetHandler(ctx, handler)
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	wait("after start")
+
+	// Stopping the only watched stream is not a
+	// lost connection.
+	_, err = l.SetHandler(ctx, pmd.ECGHandler(nil))
+	if err != nil {
+		t.Fatalf("failed to stop stream: %v", err)
+	}
+	select {
+	case o := <-outages:
+		t.Fatalf("unexpected outage with stopped stream: %+v", o)
+	case <-time.After(2 * timeout):
+	}
+	_, err = l.SetHandler(ctx, handler)
+	if err != nil {
+		t.Fatalf("failed to restart stream: %v", err)
+	}
+	wait("after restart")
+
+	// Drop the connection without signalling Lost.
+	mu.Lock()
+	sensors[0].Disconnect()
+	mu.Unlock()
+	for i := range 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("outage %d not reported", i)
+		case o := <-outages:
+			if o.Attempts < 1 {
+				t.Errorf("unexpected number of attempts for outage %d: %d", i, o.Attempts)
+			}
+			if o.Restored.Before(o.Lost) {
+				t.Errorf("outage %d restored before it was lost: %+v", i, o)
+			}
+		}
+	}
+	select {
+	case <-frames:
+	default:
+	}
+	wait("after reconnection")
+
+	mu.Lock()
+	n := len(sensors)
+	mu.Unlock()
+	if n != 3 {
+		t.Errorf("unexpected number of connections: got:%d want:3", n)
+	}
+}
+
+var (
+	pmdService = must(bluetooth.ParseUUID(pmd.ServiceID))
+	pmdData    = must(bluetooth.ParseUUID(pmd.DataID))
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// mutedDevice is a gatt.Device that never delivers notifications
+// from the PMD data characteristic.
+type mutedDevice struct {
+	gatt.Device
+}
+
+func (d mutedDevice) Characteristic(srvID, charID bluetooth.UUID) (gatt.Characteristic, error) {
+	c, err := d.Device.Characteristic(srvID, charID)
+	if err != nil || charID != pmdData {
+		return c, err
+	}
+	return mutedCharacteristic{c}, nil
+}
+
+type mutedCharacteristic struct {
+	gatt.Characteristic
+}
+
+func (mutedCharacteristic) EnableNotifications(func(buf []byte)) error {
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package record implements recording and replay of raw Bluetooth
+// sessions with Polar sensors.
+//
+// A Recorder wraps a gatt.Device and captures every notification, read
+// and write on the characteristics obtained through it. Constructing
+// pmd.Listener and heart.RateListener values with a Recorder captures
+// their raw notifications. A Replayer is a gatt.Device that feeds a
+// recorded session back through the same decoders and handlers.
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Kind is the kind of a recorded event.
+type Kind uint8
+
+const (
+	Notification Kind = 1 // Notification from the peripheral.
+	Read         Kind = 2 // Characteristic read.
+	Write        Kind = 3 // Characteristic write without response.
+)
+
+// Record is a recorded event.
+type Record struct {
+	Kind Kind
+	UUID bluetooth.UUID // Characteristic UUID.
+	Time time.Time      // Time of receipt.
+	Data []byte
+}
+
+// The recording format is a header followed by a sequence of records.
+//
+// The header is the magic string and a uvarint version, followed by
+// the start time of the recording as a varint Unix time in nanoseconds.
+//
+// Each record is a kind byte. Kind zero records define a characteristic
+// UUID and are followed by the 16 byte UUID, which is assigned the next
+// characteristic index starting from zero. Other records are followed
+// by the uvarint index of the characteristic, the uvarint time in
+// nanoseconds since the previous record, or the start time for the first
+// record, and the uvarint length and bytes of the event data.
+const (
+	magic   = "polar-rec"
+	version = 1
+
+	defineUUID = 0
+)
+
+// Writer writes records to an underlying io.Writer.
+type Writer struct {
+	w     *bufio.Writer
+	last  time.Time
+	index map[bluetooth.UUID]uint64
+	buf   []byte
+}
+
+// NewWriter returns a new Writer that writes a recording starting at
+// the provided time to w.
+func NewWriter(w io.Writer, start time.Time) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	buf := []byte(magic)
+	buf = binary.AppendUvarint(buf, version)
+	buf = binary.AppendVarint(buf, start.UnixNano())
+	_, err := bw.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:     bw,
+		last:  start,
+		index: make(map[bluetooth.UUID]uint64),
+	}, nil
+}
+
+// Write writes the record r. Records must be written in time order.
+func (w *Writer) Write(r Record) error {
+	if r.Kind == defineUUID {
+		return fmt.Errorf("invalid record kind: %d", r.Kind)
+	}
+	buf := w.buf[:0]
+	idx, ok := w.index[r.UUID]
+	if !ok {
+		idx = uint64(len(w.index))
+		w.index[r.UUID] = idx
+		b := r.UUID.Bytes()
+		buf = append(buf, defineUUID)
+		buf = append(buf, b[:]...)
+	}
+	delta := r.Time.Sub(w.last)
+	if delta < 0 {
+		delta = 0
+	} else {
+		w.last = r.Time
+	}
+	buf = append(buf, byte(r.Kind))
+	buf = binary.AppendUvarint(buf, idx)
+	buf = binary.AppendUvarint(buf, uint64(delta))
+	buf = binary.AppendUvarint(buf, uint64(len(r.Data)))
+	buf = append(buf, r.Data...)
+	w.buf = buf
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Reader reads records from an underlying io.Reader.
+type Reader struct {
+	r     *bufio.Reader
+	start time.Time
+	last  time.Time
+	uuids []bluetooth.UUID
+}
+
+// NewReader returns a new Reader reading a recording from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	var m [len(magic)]byte
+	_, err := io.ReadFull(br, m[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if string(m[:]) != magic {
+		return nil, errors.New("not a polar recording")
+	}
+	v, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version: %w", err)
+	}
+	if v != version {
+		return nil, fmt.Errorf("unsupported recording version: %d", v)
+	}
+	start, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read start time: %w", err)
+	}
+	t := time.Unix(0, start)
+	return &Reader{r: br, start: t, last: t}, nil
+}
+
+// Start returns the start time of the recording.
+func (r *Reader) Start() time.Time {
+	return r.start
+}
+
+// Read returns the next record in the recording. At the end of the
+// recording Read returns io.EOF, and if the recording ends within a
+// record Read returns io.ErrUnexpectedEOF.
+func (r *Reader) Read() (Record, error) {
+	// defined is whether a UUID definition has been
+	// read. Definitions are always followed by a
+	// record, so the end of the recording after a
+	// definition is unexpected.
+	var defined bool
+	for {
+		kind, err := r.r.ReadByte()
+		if err != nil {
+			if defined {
+				err = noEOF(err)
+			}
+			return Record{}, err
+		}
+		if kind == defineUUID {
+			var b [16]byte
+			_, err = io.ReadFull(r.r, b[:])
+			if err != nil {
+				return Record{}, noEOF(err)
+			}
+			r.uuids = append(r.uuids, uuidFromBytes(b))
+			defined = true
+			continue
+		}
+		idx, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return Record{}, noEOF(err)
+		}
+		if idx >= uint64(len(r.uuids)) {
+			return Record{}, fmt.Errorf("undefined characteristic index: %d", idx)
+		}
+		delta, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return Record{}, noEOF(err)
+		}
+		n, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return Record{}, noEOF(err)
+		}
+		data := make([]byte, n)
+		_, err = io.ReadFull(r.r, data)
+		if err != nil {
+			return Record{}, noEOF(err)
+		}
+		r.last = r.last.Add(time.Duration(delta))
+		return Record{
+			Kind: Kind(kind),
+			UUID: r.uuids[idx],
+			Time: r.last,
+			Data: data,
+		}, nil
+	}
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF for reads within a
+// record.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// uuidFromBytes is the inverse of bluetooth.UUID.Bytes.
+func uuidFromBytes(b [16]byte) bluetooth.UUID {
+	var u bluetooth.UUID
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return u
+}
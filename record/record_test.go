@@ -0,0 +1,186 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/record"
+)
+
+var (
+	start = time.Unix(1700000000, 123456789)
+
+	// uuidA and uuidB have distinct bytes in every
+	// word to check the UUID definition encoding.
+	uuidA = bluetooth.NewUUID([16]byte{
+		0xfb, 0x00, 0x5c, 0x81, 0x02, 0xe7, 0xf3, 0x87,
+		0x1c, 0xad, 0x8a, 0xcd, 0x2d, 0x8d, 0xf0, 0xc8,
+	})
+	uuidB = bluetooth.New16BitUUID(0x2a37)
+)
+
+var roundTripRecords = []record.Record{
+	{Kind: record.Read, UUID: uuidA, Time: start, Data: []byte{0x0f, 0x00}},
+	{Kind: record.Write, UUID: uuidA, Time: start.Add(time.Millisecond), Data: []byte{0x01, 0x02}},
+	{Kind: record.Notification, UUID: uuidA, Time: start.Add(2 * time.Millisecond), Data: []byte{0xf0, 0x01, 0x02, 0x00}},
+	{Kind: record.Notification, UUID: uuidB, Time: start.Add(time.Hour), Data: bytes.Repeat([]byte{0xa5}, 300)},
+	{Kind: record.Notification, UUID: uuidA, Time: start.Add(time.Hour + time.Nanosecond), Data: []byte{}},
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := record.NewWriter(&buf, start)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	for _, r := range roundTripRecords {
+		err = w.Write(r)
+		if err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	got := readAll(t, &buf)
+	if !reflect.DeepEqual(got, roundTripRecords) {
+		t.Errorf("unexpected records:\ngot: %v\nwant:%v", got, roundTripRecords)
+	}
+}
+
+func TestNegativeDelta(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := record.NewWriter(&buf, start)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	// Records written out of order are given the
+	// time of the preceding record.
+	for _, d := range []time.Duration{time.Second, 0, 2 * time.Second, time.Second} {
+		err = w.Write(record.Record{Kind: record.Notification, UUID: uuidB, Time: start.Add(d)})
+		if err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	want := []time.Duration{time.Second, time.Second, 2 * time.Second, 2 * time.Second}
+	for i, r := range readAll(t, &buf) {
+		if got := r.Time.Sub(start); got != want[i] {
+			t.Errorf("unexpected time for record %d: got:%v want:%v", i, got, want[i])
+		}
+	}
+}
+
+func TestWriteInvalidKind(t *testing.T) {
+	w, err := record.NewWriter(io.Discard, start)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	err = w.Write(record.Record{UUID: uuidA, Time: start})
+	if err == nil {
+		t.Error("expected error for zero record kind")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := record.NewWriter(&buf, start)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	header := buf.Len()
+	err = w.Write(roundTripRecords[2])
+	if err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Every truncation within the record, including
+	// within its UUID definition, is an unexpected
+	// end of file.
+	for n := header + 1; n < len(data); n++ {
+		r, err := record.NewReader(bytes.NewReader(data[:n]))
+		if err != nil {
+			t.Fatalf("failed to read header: %v", err)
+		}
+		_, err = r.Read()
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("unexpected error for record truncated to %d of %d bytes: got:%v want:%v", n-header, len(data)-header, err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	r, err := record.NewReader(bytes.NewReader(data[:header]))
+	if err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+	_, err = r.Read()
+	if err != io.EOF {
+		t.Errorf("unexpected error for empty recording: got:%v want:%v", err, io.EOF)
+	}
+
+	for n := range header {
+		_, err := record.NewReader(bytes.NewReader(data[:n]))
+		if err == nil {
+			t.Errorf("expected error for header truncated to %d bytes", n)
+		}
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	for _, data := range []string{
+		"polar-mov\x01\x00",
+		"polar-rec\x02\x00",
+	} {
+		_, err := record.NewReader(bytes.NewReader([]byte(data)))
+		if err == nil {
+			t.Errorf("expected error for header %q", data)
+		}
+	}
+}
+
+// readAll returns all the records in the recording read from r.
+func readAll(t *testing.T, r io.Reader) []record.Record {
+	t.Helper()
+	rr, err := record.NewReader(r)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	if !rr.Start().Equal(start) {
+		t.Errorf("unexpected start time: got:%v want:%v", rr.Start(), start)
+	}
+	var recs []record.Record
+	for {
+		rec, err := rr.Read()
+		if err == io.EOF {
+			return recs
+		}
+		if err != nil {
+			t.Fatalf("failed to read record %d: %v", len(recs), err)
+		}
+		recs = append(recs, rec)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+)
+
+// Recorder is a gatt.Device that records the notifications, reads and
+// writes of the characteristics of an underlying device.
+type Recorder struct {
+	dev gatt.Device
+
+	mu  sync.Mutex
+	w   *Writer
+	err error
+}
+
+// NewRecorder returns a new Recorder that records the events of dev
+// to w.
+func NewRecorder(dev gatt.Device, w io.Writer) (*Recorder, error) {
+	rw, err := NewWriter(w, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dev: dev, w: rw}, nil
+}
+
+// Characteristic returns the specified characteristic of the underlying
+// device wrapped to record its events.
+func (r *Recorder) Characteristic(srvID, charID bluetooth.UUID) (gatt.Characteristic, error) {
+	c, err := r.dev.Characteristic(srvID, charID)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedCharacteristic{char: c, uuid: charID, rec: r}, nil
+}
+
+// Disconnect disconnects the underlying device and flushes the
+// recording.
+func (r *Recorder) Disconnect() error {
+	err := r.dev.Disconnect()
+	ferr := r.Flush()
+	if err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// Flush flushes the recording to the underlying io.Writer and returns
+// the first error encountered while recording.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.w.Flush()
+	return r.err
+}
+
+// Err returns the first error encountered while recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(kind Kind, uuid bluetooth.UUID, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.w.Write(Record{Kind: kind, UUID: uuid, Time: time.Now(), Data: data})
+}
+
+type recordedCharacteristic struct {
+	char gatt.Characteristic
+	uuid bluetooth.UUID
+	rec  *Recorder
+}
+
+func (c *recordedCharacteristic) Read(data []byte) (int, error) {
+	n, err := c.char.Read(data)
+	if n > 0 {
+		c.rec.record(Read, c.uuid, data[:n])
+	}
+	return n, err
+}
+
+func (c *recordedCharacteristic) WriteWithoutResponse(p []byte) (int, error) {
+	c.rec.record(Write, c.uuid, p)
+	return c.char.WriteWithoutResponse(p)
+}
+
+func (c *recordedCharacteristic) EnableNotifications(callback func(buf []byte)) error {
+	if callback == nil {
+		return c.char.EnableNotifications(nil)
+	}
+	return c.char.EnableNotifications(func(buf []byte) {
+		c.rec.record(Notification, c.uuid, buf)
+		callback(buf)
+	})
+}
+
+func (c *recordedCharacteristic) GetMTU() (uint16, error) {
+	return c.char.GetMTU()
+}
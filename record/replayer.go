@@ -0,0 +1,214 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+)
+
+// Replayer is a gatt.Device that replays a recorded session.
+//
+// Reads of a characteristic return the recorded read values in order,
+// repeating the last value when they are exhausted. Notifications on
+// characteristics that were written to during the recording are treated
+// as responses: a write sends the notifications that followed the first
+// unreplayed recorded write with the same data, or nothing if there is
+// no such write. All other notifications are sent by Play.
+type Replayer struct {
+	chars map[bluetooth.UUID]*replayedCharacteristic
+
+	// stream is the sequence of notifications sent by Play.
+	stream []Record
+}
+
+// NewReplayer returns a new Replayer for the recording read from r.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	rr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var recs []Record
+	for {
+		rec, err := rr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+
+	p := &Replayer{chars: make(map[bluetooth.UUID]*replayedCharacteristic)}
+	char := func(uuid bluetooth.UUID) *replayedCharacteristic {
+		c, ok := p.chars[uuid]
+		if !ok {
+			c = &replayedCharacteristic{uuid: uuid}
+			p.chars[uuid] = c
+		}
+		return c
+	}
+	for _, rec := range recs {
+		if rec.Kind == Write {
+			char(rec.UUID).written = true
+		}
+	}
+	for _, rec := range recs {
+		c := char(rec.UUID)
+		switch rec.Kind {
+		case Read:
+			c.reads = append(c.reads, rec.Data)
+		case Write:
+			c.writes = append(c.writes, exchange{req: rec.Data})
+		case Notification:
+			if !c.written {
+				p.stream = append(p.stream, rec)
+				break
+			}
+			if len(c.writes) == 0 {
+				// Unsolicited notification before
+				// any write; discard it.
+				break
+			}
+			w := &c.writes[len(c.writes)-1]
+			w.resp = append(w.resp, rec.Data)
+		}
+	}
+	return p, nil
+}
+
+// Characteristic returns the specified characteristic if it is present
+// in the recording. The service is not checked.
+func (p *Replayer) Characteristic(_, charID bluetooth.UUID) (gatt.Characteristic, error) {
+	c, ok := p.chars[charID]
+	if !ok {
+		return nil, gatt.ErrNotFound
+	}
+	return c, nil
+}
+
+// Disconnect disables all notifications.
+func (p *Replayer) Disconnect() error {
+	for _, c := range p.chars {
+		c.EnableNotifications(nil)
+	}
+	return nil
+}
+
+// Play sends the recorded notifications to the enabled notification
+// callbacks, preserving the recorded intervals between notifications
+// scaled by 1/speed. If speed is not positive, the notifications are
+// sent without delay. Notifications for characteristics that do not have
+// notifications enabled are discarded.
+func (p *Replayer) Play(ctx context.Context, speed float64) error {
+	var (
+		start time.Time
+		first time.Time
+	)
+	for i, rec := range p.stream {
+		if i == 0 {
+			start = time.Now()
+			first = rec.Time
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(rec.Time.Sub(first)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return ctx.Err()
+				case <-t.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.chars[rec.UUID].notify(rec.Data)
+	}
+	return nil
+}
+
+// exchange is a recorded write and the notifications that followed it.
+type exchange struct {
+	req  []byte
+	resp [][]byte
+	done bool
+}
+
+type replayedCharacteristic struct {
+	uuid    bluetooth.UUID
+	written bool
+
+	mu       sync.Mutex
+	reads    [][]byte
+	writes   []exchange
+	callback func([]byte)
+}
+
+func (c *replayedCharacteristic) Read(data []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.reads) == 0 {
+		return 0, fmt.Errorf("no recorded reads for characteristic %s", c.uuid)
+	}
+	n := copy(data, c.reads[0])
+	if len(c.reads) > 1 {
+		c.reads = c.reads[1:]
+	}
+	return n, nil
+}
+
+func (c *replayedCharacteristic) WriteWithoutResponse(p []byte) (int, error) {
+	c.mu.Lock()
+	var resp [][]byte
+	for i, w := range c.writes {
+		if !w.done && bytes.Equal(w.req, p) {
+			c.writes[i].done = true
+			resp = w.resp
+			break
+		}
+	}
+	c.mu.Unlock()
+	if len(resp) != 0 {
+		// Respond asynchronously as a peripheral would.
+		go func() {
+			for _, buf := range resp {
+				c.notify(buf)
+			}
+		}()
+	}
+	return len(p), nil
+}
+
+func (c *replayedCharacteristic) EnableNotifications(callback func(buf []byte)) error {
+	c.mu.Lock()
+	c.callback = callback
+	c.mu.Unlock()
+	return nil
+}
+
+// GetMTU returns the maximum length of an attribute value since the
+// MTU is not recorded.
+func (c *replayedCharacteristic) GetMTU() (uint16, error) { return 512, nil }
+
+func (c *replayedCharacteristic) notify(buf []byte) {
+	c.mu.Lock()
+	callback := c.callback
+	c.mu.Unlock()
+	if callback != nil {
+		callback(bytes.Clone(buf))
+	}
+}
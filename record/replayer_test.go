@@ -0,0 +1,227 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/pmd"
+	"github.com/kortschak/polar/record"
+	"github.com/kortschak/polar/sim"
+)
+
+func TestReplayer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// uuidA is a control point that responds to
+	// writes and uuidB carries a data stream.
+	var buf bytes.Buffer
+	w, err := record.NewWriter(&buf, start)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	for i, r := range []record.Record{
+		{Kind: record.Read, UUID: uuidA, Data: []byte{0x01}},
+		{Kind: record.Notification, UUID: uuidA, Data: []byte{0xff}}, // Unsolicited.
+		{Kind: record.Write, UUID: uuidA, Data: []byte{0x0a}},
+		{Kind: record.Notification, UUID: uuidA, Data: []byte{0xa1}},
+		{Kind: record.Notification, UUID: uuidB, Data: []byte{0xd1}},
+		{Kind: record.Write, UUID: uuidA, Data: []byte{0x0b}},
+		{Kind: record.Notification, UUID: uuidA, Data: []byte{0xb1}},
+		{Kind: record.Notification, UUID: uuidA, Data: []byte{0xb2}},
+		{Kind: record.Read, UUID: uuidA, Data: []byte{0x02}},
+		{Kind: record.Notification, UUID: uuidB, Data: []byte{0xd2}},
+	} {
+		r.Time = start.Add(time.Duration(i) * time.Millisecond)
+		err = w.Write(r)
+		if err != nil {
+			t.Fatalf("failed to write record %d: %v", i, err)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	p, err := record.NewReplayer(&buf)
+	if err != nil {
+		t.Fatalf("failed to create replayer: %v", err)
+	}
+	cp, err := p.Characteristic(uuidA, uuidA)
+	if err != nil {
+		t.Fatalf("failed to get control point: %v", err)
+	}
+	data, err := p.Characteristic(uuidB, uuidB)
+	if err != nil {
+		t.Fatalf("failed to get data characteristic: %v", err)
+	}
+
+	// Reads are returned in order, repeating the last.
+	for i, want := range []byte{0x01, 0x02, 0x02} {
+		var b [1]byte
+		_, err := cp.Read(b[:])
+		if err != nil {
+			t.Fatalf("failed to read %d: %v", i, err)
+		}
+		if b[0] != want {
+			t.Errorf("unexpected read %d: got:%#x want:%#x", i, b[0], want)
+		}
+	}
+
+	resp := make(chan byte, 4)
+	err = cp.EnableNotifications(func(buf []byte) { resp <- buf[0] })
+	if err != nil {
+		t.Fatalf("failed to enable control point notifications: %v", err)
+	}
+	write := func(req byte, want ...byte) {
+		t.Helper()
+		_, err := cp.WriteWithoutResponse([]byte{req})
+		if err != nil {
+			t.Fatalf("failed to write %#x: %v", req, err)
+		}
+		var got []byte
+		for range want {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("missing response to %#x: got:%#x want:%#x", req, got, want)
+			case b := <-resp:
+				got = append(got, b)
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("unexpected response to %#x: got:%#x want:%#x", req, got, want)
+		}
+		select {
+		case b := <-resp:
+			t.Errorf("unexpected extra response to %#x: %#x", req, b)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	// Writes are answered out of recorded order, and
+	// each recorded write is answered once.
+	write(0x0b, 0xb1, 0xb2)
+	write(0x0a, 0xa1)
+	write(0x0a)
+	write(0x0c)
+
+	var got []byte
+	err = data.EnableNotifications(func(buf []byte) { got = append(got, buf[0]) })
+	if err != nil {
+		t.Fatalf("failed to enable data notifications: %v", err)
+	}
+	err = p.Play(ctx, 0)
+	if err != nil {
+		t.Fatalf("failed to play: %v", err)
+	}
+	if want := []byte{0xd1, 0xd2}; !bytes.Equal(got, want) {
+		t.Errorf("unexpected played notifications: got:%#x want:%#x", got, want)
+	}
+}
+
+func TestReplaySimSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	s, err := sim.New(sim.H10, 60)
+	if err != nil {
+		t.Fatalf("failed to create sensor: %v", err)
+	}
+	var buf bytes.Buffer
+	rec, err := record.NewRecorder(s, &buf)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	live := session(ctx, t, rec, nil)
+	if err := rec.Err(); err != nil {
+		t.Fatalf("unexpected recording error: %v", err)
+	}
+
+	p, err := record.NewReplayer(&buf)
+	if err != nil {
+		t.Fatalf("failed to create replayer: %v", err)
+	}
+	replayed := session(ctx, t, p, func() {
+		err := p.Play(ctx, 0)
+		if err != nil {
+			t.Errorf("failed to play: %v", err)
+		}
+	})
+
+	// Notifications that arrived after the live
+	// handler was removed were recorded, so the
+	// replay may hold more frames.
+	if len(replayed) < len(live) {
+		t.Fatalf("too few replayed frames: got:%d want at least:%d", len(replayed), len(live))
+	}
+	if !reflect.DeepEqual(replayed[:len(live)], live) {
+		t.Errorf("unexpected replayed frames:\ngot: %v\nwant:%v", replayed[:len(live)], live)
+	}
+}
+
+// session runs an ECG stream on dev with a pmd.Listener and returns
+// the decoded frames. If play is nil, frames are collected until at
+// least two have been received. Otherwise play is called after the
+// stream has been started and the frames received until it returns
+// are collected.
+func session(ctx context.Context, t *testing.T, dev gatt.Device, play func()) []pmd.ECG {
+	t.Helper()
+	l, err := pmd.NewListener(dev)
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	defer l.Close()
+
+	var (
+		mu     sync.Mutex
+		frames []pmd.ECG
+	)
+	received := make(chan struct{}, 1)
+	_, err = l.SetHandler(ctx, pmd.ECGHandler(func(buf []byte) {
+		var m pmd.ECG
+		err := m.UnmarshalBinary(buf)
+		if err != nil {
+			t.Errorf("failed to decode ecg frame: %v", err)
+			return
+		}
+		mu.Lock()
+		frames = append(frames, m)
+		mu.Unlock()
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	}))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	if play != nil {
+		play()
+	} else {
+		for n := 0; n < 2; {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("too few frames received: %d", n)
+			case <-received:
+				mu.Lock()
+				n = len(frames)
+				mu.Unlock()
+			}
+		}
+	}
+	_, err = l.SetHandler(ctx, pmd.ECGHandler(nil))
+	if err != nil {
+		t.Fatalf("failed to stop stream: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return frames
+}
@@ -0,0 +1,285 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/kortschak/polar/pmd"
+)
+
+// measurement is a simulated PMD measurement stream.
+type measurement struct {
+	rates      []uint16 // Hz
+	resolution uint16   // bits
+	ranges     []uint16
+	channels   uint8
+
+	// sdkRates and sdkRanges are the sample rates and
+	// ranges available in SDK mode. If nil, rates and
+	// ranges are used.
+	sdkRates  []uint16 // Hz
+	sdkRanges []uint16
+
+	// factor is the conversion factor returned on start.
+	// It is not returned if zero.
+	factor float32
+
+	// frameType is the frame type byte of notifications,
+	// including the compression flag.
+	frameType byte
+	// perFrame is the number of samples in each
+	// notification.
+	perFrame int
+	// encode returns the notification data for samples
+	// at the provided times.
+	encode func(s *Sensor, times []time.Time) []byte
+}
+
+var h10 = map[pmd.MeasureType]*measurement{
+	pmd.ECGType: {
+		rates:      []uint16{pmd.ECGSampleFreq},
+		resolution: pmd.ECGResolution,
+		frameType:  byte(pmd.ECGFrameType0),
+		perFrame:   73,
+		encode: func(s *Sensor, times []time.Time) []byte {
+			buf := make([]byte, 0, 3*len(times))
+			for _, t := range times {
+				v := s.heart.ecg(t)
+				buf = append(buf, byte(v), byte(v>>8), byte(v>>16))
+			}
+			return buf
+		},
+	},
+	pmd.AccType: {
+		rates:      []uint16{25, 50, 100, 200},
+		resolution: 16,
+		ranges:     []uint16{2, 4, 8},
+		frameType:  byte(pmd.AccFrameType1),
+		perFrame:   36,
+		encode: func(s *Sensor, times []time.Time) []byte {
+			buf := make([]byte, 0, 6*len(times))
+			for _, v := range s.vectors(times, acc, 1) {
+				buf = binary.LittleEndian.AppendUint16(buf, uint16(v))
+			}
+			return buf
+		},
+	},
+}
+
+var veritySense = map[pmd.MeasureType]*measurement{
+	pmd.PPGType: {
+		rates:      []uint16{55},
+		sdkRates:   []uint16{28, 44, 55, 135, 176},
+		resolution: pmd.PPGResolution,
+		channels:   4,
+		frameType:  byte(pmd.PPGFrameType0) | compressed,
+		perFrame:   36,
+		encode: func(s *Sensor, times []time.Time) []byte {
+			values := make([]int32, 0, 4*len(times))
+			for _, t := range times {
+				ch, amb := s.heart.ppg(t)
+				values = append(values, ch[0], ch[1], ch[2], amb)
+			}
+			return deltaEncode(values, 4, pmd.PPGResolution)
+		},
+	},
+	pmd.PPIType: {
+		frameType: byte(pmd.PPIFrameType0),
+	},
+	pmd.AccType: {
+		rates:      []uint16{52},
+		sdkRates:   []uint16{26, 52, 104, 208, 416},
+		resolution: 16,
+		ranges:     []uint16{8},
+		sdkRanges:  []uint16{2, 4, 8, 16},
+		factor:     accFactor,
+		frameType:  byte(pmd.AccFrameType1) | compressed,
+		perFrame:   36,
+		encode: func(s *Sensor, times []time.Time) []byte {
+			return deltaEncode(s.vectors(times, acc, accFactor), 3, 16)
+		},
+	},
+	pmd.GyroType: {
+		rates:      []uint16{52},
+		sdkRates:   []uint16{26, 52, 104, 208, 416},
+		resolution: pmd.GyroResolution,
+		ranges:     []uint16{250, 500, 1000, 2000},
+		factor:     gyroFactor,
+		frameType:  byte(pmd.GyroFrameType0) | compressed,
+		perFrame:   36,
+		encode: func(s *Sensor, times []time.Time) []byte {
+			return deltaEncode(s.vectors(times, gyro, gyroFactor), 3, pmd.GyroResolution)
+		},
+	},
+	pmd.MagnetometerType: {
+		rates:      []uint16{10, 20, 50, 100},
+		resolution: pmd.MagResolution,
+		ranges:     []uint16{50},
+		factor:     magFactor,
+		frameType:  byte(pmd.MagnetometerFrameType0) | compressed,
+		perFrame:   36,
+		encode: func(s *Sensor, times []time.Time) []byte {
+			return deltaEncode(s.vectors(times, mag, magFactor), 3, pmd.MagResolution)
+		},
+	},
+}
+
+const (
+	accFactor  = 16000.0 / (1 << 15) // mG
+	gyroFactor = 2000.0 / (1 << 15)  // deg/s
+	magFactor  = 50.0 / (1 << 15)    // G
+)
+
+// vectors returns the X, Y, Z interleaved values of fn at the provided
+// times, divided by factor.
+func (s *Sensor) vectors(times []time.Time, fn func(float64) [3]float64, factor float64) []int32 {
+	values := make([]int32, 0, 3*len(times))
+	for _, t := range times {
+		for _, v := range fn(t.Sub(s.start).Seconds()) {
+			values = append(values, int32(math.Round(v/factor)))
+		}
+	}
+	return values
+}
+
+// available returns the sample rates and ranges available for the
+// measurement with SDK mode enabled or disabled.
+func (m *measurement) available(sdk bool) (rates, ranges []uint16) {
+	rates, ranges = m.rates, m.ranges
+	if sdk {
+		if m.sdkRates != nil {
+			rates = m.sdkRates
+		}
+		if m.sdkRanges != nil {
+			ranges = m.sdkRanges
+		}
+	}
+	return rates, ranges
+}
+
+// settings returns the encoded settings available for the measurement
+// with SDK mode enabled or disabled.
+func (m *measurement) settings(sdk bool) []byte {
+	rates, ranges := m.available(sdk)
+	var buf []byte
+	if len(rates) != 0 {
+		buf = appendUint16Setting(buf, pmd.SampleRateSetting, rates...)
+	}
+	if m.resolution != 0 {
+		buf = appendUint16Setting(buf, pmd.ResolutionSetting, m.resolution)
+	}
+	if len(ranges) != 0 {
+		buf = appendUint16Setting(buf, pmd.RangeUnitSetting, ranges...)
+	}
+	if m.channels != 0 {
+		buf = append(buf, byte(pmd.ChannelsSetting), 1, m.channels)
+	}
+	return buf
+}
+
+// startParams returns the encoded parameters of the response to
+// starting the measurement.
+func (m *measurement) startParams() []byte {
+	if m.factor == 0 {
+		return nil
+	}
+	buf := []byte{byte(pmd.ConversionFactorSetting), 1}
+	return binary.LittleEndian.AppendUint32(buf, math.Float32bits(m.factor))
+}
+
+func appendUint16Setting(dst []byte, typ pmd.SettingType, vals ...uint16) []byte {
+	dst = append(dst, byte(typ), byte(len(vals)))
+	for _, v := range vals {
+		dst = binary.LittleEndian.AppendUint16(dst, v)
+	}
+	return dst
+}
+
+// epoch is the PMD timestamp epoch.
+var epoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// frame returns a PMD notification for the measurement type holding
+// data, with last as the time of the last sample.
+func frame(typ pmd.MeasureType, last time.Time, frameType byte, data []byte) []byte {
+	buf := make([]byte, 0, 10+len(data))
+	buf = append(buf, byte(typ))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(last.Sub(epoch)))
+	buf = append(buf, frameType)
+	return append(buf, data...)
+}
+
+// compressed is the PMD frame type flag for delta-compressed frames.
+const compressed = 0x80
+
+// deltaEncode returns the channel interleaved samples encoded as a
+// delta-compressed PMD frame payload with a single delta block.
+func deltaEncode(samples []int32, channels, resolution int) []byte {
+	width := (resolution + 7) / 8
+	var buf []byte
+	for _, v := range samples[:channels] {
+		for b := range width {
+			buf = append(buf, byte(v>>(8*b)))
+		}
+	}
+	n := len(samples)/channels - 1
+	if n <= 0 {
+		return buf
+	}
+	deltas := make([]int32, 0, n*channels)
+	size := 0
+	for i := channels; i < len(samples); i++ {
+		d := samples[i] - samples[i-channels]
+		deltas = append(deltas, d)
+		if d < 0 {
+			d = ^d
+		}
+		size = max(size, bits.Len32(uint32(d))+1)
+	}
+	buf = append(buf, byte(size), byte(n))
+	var (
+		acc  uint64
+		fill int
+	)
+	for _, d := range deltas {
+		acc |= uint64(uint32(d)&(1<<size-1)) << fill
+		fill += size
+		for fill >= 8 {
+			buf = append(buf, byte(acc))
+			acc >>= 8
+			fill -= 8
+		}
+	}
+	if fill != 0 {
+		buf = append(buf, byte(acc))
+	}
+	return buf
+}
+
+// ppiSamples returns the PPI frame data for the provided RR intervals.
+func ppiSamples(rr []time.Duration) []byte {
+	buf := make([]byte, 0, 6*len(rr))
+	for _, d := range rr {
+		buf = append(buf, byte(math.Round(float64(time.Minute)/float64(d))))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(d.Milliseconds()))
+		buf = binary.LittleEndian.AppendUint16(buf, 10) // ms error estimate.
+		buf = append(buf, 0x6)                          // Skin contact supported and detected.
+	}
+	return buf
+}
+
+// heartRateMeasurement returns a 2a37 heart rate measurement with
+// the provided rate and RR intervals.
+func heartRateMeasurement(bpm float64, rr []time.Duration) []byte {
+	// Flags: RR present, contact supported and detected, 8-bit rate.
+	buf := []byte{0x16, byte(min(math.Round(bpm), 255))}
+	for _, d := range rr {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(d*1024/time.Second))
+	}
+	return buf
+}
@@ -0,0 +1,168 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rhythm is a simulated heart beat schedule.
+type rhythm struct {
+	mu    sync.Mutex
+	bpm   float64
+	beats []time.Time // R-peak times.
+	n     int         // Number of beats generated.
+}
+
+func newRhythm(start time.Time, bpm float64) *rhythm {
+	return &rhythm{bpm: bpm, beats: []time.Time{start}}
+}
+
+func (r *rhythm) setRate(bpm float64) {
+	if bpm <= 0 {
+		return
+	}
+	r.mu.Lock()
+	r.bpm = bpm
+	r.mu.Unlock()
+}
+
+func (r *rhythm) rate() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bpm
+}
+
+// extend ensures that the schedule holds a beat after t and discards
+// beats that are more than a minute older than t. It must be called
+// with r.mu held.
+func (r *rhythm) extend(t time.Time) {
+	for !r.beats[len(r.beats)-1].After(t) {
+		// Add a respiratory sinus arrhythmia of about 4%.
+		rr := time.Duration(float64(time.Minute) / r.bpm * (1 + 0.04*math.Sin(2*math.Pi*float64(r.n)/5)))
+		r.beats = append(r.beats, r.beats[len(r.beats)-1].Add(rr))
+		r.n++
+	}
+	old := sort.Search(len(r.beats), func(i int) bool {
+		return r.beats[i].After(t.Add(-time.Minute))
+	})
+	if old > 1 {
+		r.beats = append(r.beats[:0], r.beats[old-1:]...)
+	}
+}
+
+// around returns the last beat at or before t and the first beat after
+// t. If t is before the first beat, prev is the zero time.
+func (r *rhythm) around(t time.Time) (prev, next time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extend(t)
+	i := sort.Search(len(r.beats), func(i int) bool {
+		return r.beats[i].After(t)
+	})
+	if i > 0 {
+		prev = r.beats[i-1]
+	}
+	return prev, r.beats[i]
+}
+
+// between returns the beats in (a, b] and the RR interval preceding
+// each of them.
+func (r *rhythm) between(a, b time.Time) ([]time.Time, []time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extend(b)
+	var (
+		beats []time.Time
+		rr    []time.Duration
+	)
+	for i := 1; i < len(r.beats); i++ {
+		t := r.beats[i]
+		if !t.After(a) || t.After(b) {
+			continue
+		}
+		beats = append(beats, t)
+		rr = append(rr, t.Sub(r.beats[i-1]))
+	}
+	return beats, rr
+}
+
+// ecg returns the simulated ECG voltage at t in µV.
+func (r *rhythm) ecg(t time.Time) int32 {
+	prev, next := r.around(t)
+	var v float64
+	if !prev.IsZero() {
+		v += pqrst(t.Sub(prev))
+	}
+	v += pqrst(t.Sub(next))
+	return int32(math.Round(v + 5*rand.NormFloat64()))
+}
+
+// pqrst returns the ECG voltage in µV of a single beat at dt from its
+// R-peak.
+func pqrst(dt time.Duration) float64 {
+	x := dt.Seconds()
+	return gaussian(x, -0.16, 0.02, 120) + // P
+		gaussian(x, -0.03, 0.008, -100) + // Q
+		gaussian(x, 0, 0.01, 1100) + // R
+		gaussian(x, 0.03, 0.01, -250) + // S
+		gaussian(x, 0.25, 0.04, 280) // T
+}
+
+// ppg returns the simulated optical channel values and ambient light
+// value at t.
+func (r *rhythm) ppg(t time.Time) (channels [3]int32, ambient int32) {
+	prev, _ := r.around(t)
+	var pulse float64
+	if !prev.IsZero() {
+		// The pulse wave arrives at the periphery
+		// about 250ms after the R-peak.
+		x := t.Sub(prev).Seconds()
+		pulse = gaussian(x, 0.25, 0.08, 1) + gaussian(x, 0.5, 0.1, 0.4)
+	}
+	for i := range channels {
+		base := 180000 - 5000*float64(i)
+		channels[i] = int32(math.Round(base - 6000*pulse*(1-0.1*float64(i)) + 50*rand.NormFloat64()))
+	}
+	ambient = int32(math.Round(1500 + 20*rand.NormFloat64()))
+	return channels, ambient
+}
+
+func gaussian(x, mu, sigma, amp float64) float64 {
+	d := (x - mu) / sigma
+	return amp * math.Exp(-d*d/2)
+}
+
+// acc returns the simulated acceleration at t seconds in mG.
+func acc(t float64) [3]float64 {
+	return [3]float64{
+		30 * math.Sin(2*math.Pi*0.25*t),
+		-50 + 20*math.Sin(2*math.Pi*0.5*t),
+		1000 + 10*math.Sin(2*math.Pi*t),
+	}
+}
+
+// gyro returns the simulated angular velocity at t seconds in deg/s.
+func gyro(t float64) [3]float64 {
+	return [3]float64{
+		5 * math.Sin(2*math.Pi*0.3*t),
+		3 * math.Cos(2*math.Pi*0.2*t),
+		1,
+	}
+}
+
+// mag returns the simulated magnetic field at t seconds in G.
+func mag(t float64) [3]float64 {
+	theta := 0.2 * math.Sin(2*math.Pi*0.05*t)
+	return [3]float64{
+		0.2 * math.Cos(theta),
+		0.2 * math.Sin(theta),
+		-0.45,
+	}
+}
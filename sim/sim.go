@@ -0,0 +1,703 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sim provides a simulated Polar sensor for testing and
+// demonstration without Bluetooth hardware.
+//
+// A Sensor implements gatt.Device with the PMD, heart rate, battery and
+// device information services, and so can be used with
+// pmd.NewListener, heart.NewRateListener, battery.Level and
+// devinfo.Read.
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/battery"
+	"github.com/kortschak/polar/devinfo"
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/heart"
+	"github.com/kortschak/polar/pmd"
+)
+
+var (
+	pmdService = must(bluetooth.ParseUUID(pmd.ServiceID))
+	pmdCP      = must(bluetooth.ParseUUID(pmd.ControlPointID))
+	pmdData    = must(bluetooth.ParseUUID(pmd.DataID))
+
+	hrService     = must(bluetooth.ParseUUID(heart.RateServiceID))
+	hrMeasurement = must(bluetooth.ParseUUID(heart.RateMeasurementID))
+
+	batteryService             = must(bluetooth.ParseUUID(battery.ServiceID))
+	batteryLevelCharacteristic = must(bluetooth.ParseUUID(battery.LevelCharacteristicID))
+
+	infoService      = must(bluetooth.ParseUUID(devinfo.ServiceID))
+	manufacturerName = must(bluetooth.ParseUUID(devinfo.ManufacturerNameCharacteristicID))
+	modelNumber      = must(bluetooth.ParseUUID(devinfo.ModelNumberCharacteristicID))
+	serialNumber     = must(bluetooth.ParseUUID(devinfo.SerialNumberCharacteristicID))
+	hardwareRevision = must(bluetooth.ParseUUID(devinfo.HardwareRevisionCharacteristicID))
+	firmwareRevision = must(bluetooth.ParseUUID(devinfo.FirmwareRevisionCharacteristicID))
+	systemID         = must(bluetooth.ParseUUID(devinfo.SystemIDCharacteristicID))
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// ErrNotConnected is returned by Sensor methods when the simulated
+// sensor has been disconnected.
+var ErrNotConnected = errors.New("sim: sensor not connected")
+
+// Model is a simulated Polar sensor model.
+type Model int
+
+const (
+	// H10 is a Polar H10 chest strap with ECG and
+	// accelerometer streams.
+	H10 Model = iota
+	// VeritySense is a Polar Verity Sense optical sensor
+	// with PPG, PPI, accelerometer, gyroscope and
+	// magnetometer streams.
+	VeritySense
+)
+
+// Sensor is a simulated Polar sensor. It answers PMD control point
+// settings, measurement status and SDK mode queries, start and stop
+// commands and, for models that support them, SDK mode changes and
+// offline recording trigger configuration. It sends PMD measurement
+// notifications for started streams, sends heart rate notifications
+// with RR intervals and reports its battery level.
+type Sensor struct {
+	model    Model
+	features [2]byte
+	measures map[pmd.MeasureType]*measurement
+	sdk      bool // SDK mode is supported.
+
+	start time.Time
+	heart *rhythm
+
+	mu        sync.Mutex
+	connected bool
+	done      chan struct{}
+	streams   map[pmd.MeasureType]chan struct{}
+	sdkMode   bool
+	level     byte
+	mtu       int
+	wg        sync.WaitGroup
+
+	// triggerMode and triggers are the offline
+	// recording trigger mode and the encoded
+	// settings of enabled triggers.
+	triggerMode byte
+	triggers    map[pmd.MeasureType][]byte
+
+	cp, data, hr, battery *characteristic
+	info                  map[bluetooth.UUID]*characteristic
+}
+
+// New returns a new connected simulated sensor of the given model
+// with a resting heart rate of bpm beats per minute.
+func New(model Model, bpm float64) (*Sensor, error) {
+	var (
+		features [2]byte
+		measures map[pmd.MeasureType]*measurement
+		sdk      bool
+		info     map[bluetooth.UUID]string
+	)
+	switch model {
+	case H10:
+		features = [2]byte{0xf, byte(pmd.SupportECG | pmd.SupportAcc)}
+		measures = h10
+		info = map[bluetooth.UUID]string{
+			modelNumber:      "Polar H10",
+			serialNumber:     "C4A1B2C3",
+			hardwareRevision: "39044024.10",
+			firmwareRevision: "5.0.0",
+		}
+	case VeritySense:
+		features = [2]byte{0xf, byte(pmd.SupportPPG | pmd.SupportAcc | pmd.SupportPPI | pmd.SupportGyro | pmd.SupportMag)}
+		measures = veritySense
+		sdk = true
+		info = map[bluetooth.UUID]string{
+			modelNumber:      "Polar Sense",
+			serialNumber:     "E5D6C7B8",
+			hardwareRevision: "00285201.00",
+			firmwareRevision: "2.1.0",
+		}
+	default:
+		return nil, fmt.Errorf("sim: unknown model: %d", model)
+	}
+	if bpm <= 0 {
+		return nil, fmt.Errorf("sim: invalid heart rate: %v", bpm)
+	}
+	now := time.Now()
+	s := &Sensor{
+		model:     model,
+		features:  features,
+		measures:  measures,
+		sdk:       sdk,
+		start:     now,
+		heart:     newRhythm(now, bpm),
+		connected: true,
+		done:      make(chan struct{}),
+		streams:   make(map[pmd.MeasureType]chan struct{}),
+		triggers:  make(map[pmd.MeasureType][]byte),
+		level:     100,
+		mtu:       defaultMTU,
+	}
+	s.cp = &characteristic{
+		s:    s,
+		uuid: pmdCP,
+		read: func() []byte {
+			// The features are followed by undocumented
+			// data; pad to the length sent by sensors.
+			buf := make([]byte, 17)
+			copy(buf, s.features[:])
+			return buf
+		},
+		write: func(p []byte) { go s.command(p) },
+	}
+	s.data = &characteristic{s: s, uuid: pmdData}
+	s.hr = &characteristic{s: s, uuid: hrMeasurement, enable: s.heartRate}
+	s.battery = &characteristic{
+		s:    s,
+		uuid: batteryLevelCharacteristic,
+		read: func() []byte {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return []byte{s.level}
+		},
+	}
+	info[manufacturerName] = "Polar Electro Oy"
+	s.info = make(map[bluetooth.UUID]*characteristic)
+	for id, v := range info {
+		s.info[id] = &characteristic{
+			s:    s,
+			uuid: id,
+			read: func() []byte { return []byte(v) },
+		}
+	}
+	s.info[systemID] = &characteristic{
+		s:    s,
+		uuid: systemID,
+		read: func() []byte { return []byte{0xc3, 0xb2, 0xa1, 0xfe, 0xff, 0x1a, 0x9e, 0xa0} },
+	}
+	return s, nil
+}
+
+// Characteristic returns the specified characteristic of the specified
+// service of the simulated sensor.
+func (s *Sensor) Characteristic(srvID, charID bluetooth.UUID) (gatt.Characteristic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.connected {
+		return nil, ErrNotConnected
+	}
+	var char *characteristic
+	switch srvID {
+	case pmdService:
+		switch charID {
+		case pmdCP:
+			char = s.cp
+		case pmdData:
+			char = s.data
+		}
+	case hrService:
+		if charID == hrMeasurement {
+			char = s.hr
+		}
+	case batteryService:
+		if charID == batteryLevelCharacteristic {
+			char = s.battery
+		}
+	case infoService:
+		char = s.info[charID]
+	}
+	if char == nil {
+		return nil, gatt.ErrNotFound
+	}
+	return char, nil
+}
+
+// Disconnect stops all streams and notifications and disconnects the
+// simulated sensor.
+func (s *Sensor) Disconnect() error {
+	s.mu.Lock()
+	if !s.connected {
+		s.mu.Unlock()
+		return nil
+	}
+	s.connected = false
+	close(s.done)
+	clear(s.streams)
+	s.mu.Unlock()
+	s.wg.Wait()
+	for _, c := range []*characteristic{s.cp, s.data, s.hr, s.battery} {
+		c.EnableNotifications(nil)
+	}
+	return nil
+}
+
+// SetHeartRate sets the resting heart rate of the simulated sensor's
+// wearer in beats per minute.
+func (s *Sensor) SetHeartRate(bpm float64) {
+	s.heart.setRate(bpm)
+}
+
+// SetMTU sets the ATT MTU of the simulated connection. Control point
+// responses longer than the notification payload of mtu-3 bytes are
+// split across notifications, so a small MTU can be used to exercise
+// the reassembly of multi-part responses. Values below 23, the minimum
+// MTU allowed by Bluetooth LE, are treated as 23.
+func (s *Sensor) SetMTU(mtu int) {
+	s.mu.Lock()
+	s.mtu = min(max(mtu, minMTU), math.MaxUint16)
+	s.mu.Unlock()
+}
+
+// SetBattery sets the battery level of the simulated sensor as a
+// percentage, sending a notification if the level has changed.
+func (s *Sensor) SetBattery(percent int) {
+	level := byte(min(max(percent, 0), 100))
+	s.mu.Lock()
+	changed := level != s.level
+	s.level = level
+	s.mu.Unlock()
+	if changed {
+		s.battery.notify([]byte{level})
+	}
+}
+
+// command handles a PMD control point command.
+func (s *Sensor) command(msg []byte) {
+	if len(msg) == 0 {
+		return
+	}
+	op := msg[0]
+	var typ byte
+	if len(msg) > 1 {
+		typ = msg[1]
+	}
+	respond := func(status pmd.Status, params ...byte) {
+		s.respond(append([]byte{0xf0, op, typ, byte(status), 0}, params...))
+	}
+	switch pmd.Command(op) {
+	case pmd.MeasureStatus:
+		respond(pmd.Success, s.status()...)
+		return
+	case pmd.SDKModeStatus:
+		if !s.sdk {
+			respond(pmd.NotSupported)
+			return
+		}
+		s.mu.Lock()
+		enabled := s.sdkMode
+		s.mu.Unlock()
+		if enabled {
+			respond(pmd.Success, 1)
+		} else {
+			respond(pmd.Success, 0)
+		}
+		return
+	case pmd.OfflineTriggerStatus, pmd.OfflineTriggerMode, pmd.OfflineTriggerSettings:
+		if !s.sdk {
+			// Offline recording is only supported
+			// by the models that support SDK mode.
+			respond(pmd.NotSupported)
+			return
+		}
+		status, params := s.trigger(pmd.Command(op), msg[1:])
+		respond(status, params...)
+		return
+	}
+	if len(msg) < 2 {
+		respond(pmd.InvalidLength)
+		return
+	}
+	measure := pmd.MeasureType(typ &^ 0x80)
+	offline := typ&0x80 != 0
+
+	if measure == pmd.SDKModeType {
+		switch pmd.Command(op) {
+		case pmd.MeasureStart, pmd.MeasureStop:
+			if !s.sdk || offline {
+				respond(pmd.NotSupported)
+				return
+			}
+			respond(s.setSDKMode(pmd.Command(op) == pmd.MeasureStart))
+		default:
+			respond(pmd.InvalidOpCode)
+		}
+		return
+	}
+
+	m, ok := s.measures[measure]
+	switch pmd.Command(op) {
+	case pmd.MeasureSettings, pmd.MeasureStart, pmd.MeasureStop:
+		if !ok || offline {
+			respond(pmd.NotSupported)
+			return
+		}
+	case pmd.SDKModeSettings:
+		if !ok || offline || !s.sdk {
+			respond(pmd.NotSupported)
+			return
+		}
+	default:
+		respond(pmd.InvalidOpCode)
+		return
+	}
+
+	s.mu.Lock()
+	sdkMode := s.sdkMode
+	s.mu.Unlock()
+	switch pmd.Command(op) {
+	case pmd.MeasureSettings:
+		respond(pmd.Success, m.settings(sdkMode)...)
+
+	case pmd.SDKModeSettings:
+		respond(pmd.Success, m.settings(true)...)
+
+	case pmd.MeasureStart:
+		rates, _ := m.available(sdkMode)
+		var rate uint16
+		if len(rates) != 0 {
+			rate = rates[0]
+		}
+		if r, ok := sampleRate(msg[2:]); ok {
+			rate = r
+		}
+		if len(rates) != 0 && !slices.Contains(rates, rate) {
+			respond(pmd.InvalidSampleRate)
+			return
+		}
+		s.mu.Lock()
+		if !s.connected {
+			s.mu.Unlock()
+			return
+		}
+		if _, running := s.streams[measure]; running {
+			s.mu.Unlock()
+			respond(pmd.AlreadyInState)
+			return
+		}
+		stop := make(chan struct{})
+		s.streams[measure] = stop
+		s.wg.Add(1)
+		s.mu.Unlock()
+		respond(pmd.Success, m.startParams()...)
+		go func() {
+			defer s.wg.Done()
+			if measure == pmd.PPIType {
+				s.ppi(stop)
+			} else {
+				s.stream(measure, m, rate, stop)
+			}
+		}()
+
+	case pmd.MeasureStop:
+		s.mu.Lock()
+		if stop, running := s.streams[measure]; running {
+			close(stop)
+			delete(s.streams, measure)
+		}
+		s.mu.Unlock()
+		respond(pmd.Success)
+	}
+}
+
+// respond sends a control point response. Responses longer than the
+// notification payload size are split into a first notification with
+// the more flag set and continuation notifications each starting with
+// a more flag.
+func (s *Sensor) respond(resp []byte) {
+	s.mu.Lock()
+	size := s.mtu - attHeaderSize
+	s.mu.Unlock()
+	if len(resp) <= size {
+		s.cp.notify(resp)
+		return
+	}
+	first := bytes.Clone(resp[:size])
+	first[4] = 1
+	s.cp.notify(first)
+	for rest := resp[size:]; len(rest) != 0; {
+		n := min(len(rest), size-1)
+		var more byte
+		if n < len(rest) {
+			more = 1
+		}
+		s.cp.notify(append([]byte{more}, rest[:n]...))
+		rest = rest[n:]
+	}
+}
+
+// setSDKMode enables or disables SDK mode, stopping all running
+// streams.
+func (s *Sensor) setSDKMode(enable bool) pmd.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sdkMode == enable {
+		return pmd.AlreadyInState
+	}
+	s.sdkMode = enable
+	for m, stop := range s.streams {
+		close(stop)
+		delete(s.streams, m)
+	}
+	return pmd.Success
+}
+
+// trigger handles an offline recording trigger command with the
+// provided parameters.
+func (s *Sensor) trigger(op pmd.Command, params []byte) (pmd.Status, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch op {
+	case pmd.OfflineTriggerStatus:
+		status := []byte{s.triggerMode}
+		for _, m := range slices.Sorted(maps.Keys(s.measures)) {
+			settings, enabled := s.triggers[m]
+			if !enabled {
+				status = append(status, 0, byte(m))
+				continue
+			}
+			status = append(status, 1, byte(m), byte(len(settings)))
+			status = append(status, settings...)
+		}
+		return pmd.Success, status
+	case pmd.OfflineTriggerMode:
+		if len(params) != 1 {
+			return pmd.InvalidLength, nil
+		}
+		if params[0] > byte(pmd.TriggerExerciseStart) {
+			return pmd.InvalidParameter, nil
+		}
+		s.triggerMode = params[0]
+		return pmd.Success, nil
+	case pmd.OfflineTriggerSettings:
+		if len(params) < 2 {
+			return pmd.InvalidLength, nil
+		}
+		m := pmd.MeasureType(params[1])
+		if _, ok := s.measures[m]; !ok {
+			return pmd.NotSupported, nil
+		}
+		if params[0] == 0 {
+			delete(s.triggers, m)
+		} else {
+			s.triggers[m] = bytes.Clone(params[2:])
+		}
+		return pmd.Success, nil
+	default:
+		return pmd.InvalidOpCode, nil
+	}
+}
+
+// status returns the measurement status parameters for the running
+// streams.
+func (s *Sensor) status() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The simulated sensors do not record
+	// offline, so only the online flag is set.
+	var params []byte
+	for m := range s.streams {
+		params = append(params, 0x40|byte(m))
+	}
+	slices.Sort(params)
+	return params
+}
+
+// sampleRate returns the sample rate in the settings of a start
+// command.
+func sampleRate(settings []byte) (uint16, bool) {
+	for len(settings) >= 2 {
+		typ, n := pmd.SettingType(settings[0]), int(settings[1])
+		settings = settings[2:]
+		var size int
+		switch typ {
+		case pmd.SampleRateSetting, pmd.ResolutionSetting, pmd.RangeUnitSetting:
+			size = 2
+		case pmd.ChannelsSetting:
+			size = 1
+		case pmd.ConversionFactorSetting:
+			size = 4
+		default:
+			return 0, false
+		}
+		if len(settings) < n*size {
+			return 0, false
+		}
+		if typ == pmd.SampleRateSetting && n != 0 {
+			return uint16(settings[0]) | uint16(settings[1])<<8, true
+		}
+		settings = settings[n*size:]
+	}
+	return 0, false
+}
+
+// stream sends notifications for the measurement m at the given sample
+// rate until stop or the sensor's done channel is closed.
+func (s *Sensor) stream(typ pmd.MeasureType, m *measurement, rate uint16, stop <-chan struct{}) {
+	interval := time.Second / time.Duration(rate)
+	start := time.Now()
+	ticker := time.NewTicker(time.Duration(m.perFrame) * interval)
+	defer ticker.Stop()
+	times := make([]time.Time, m.perFrame)
+	for next := 0; ; next += m.perFrame {
+		select {
+		case <-stop:
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		for i := range times {
+			times[i] = start.Add(time.Duration(next+i) * interval)
+		}
+		s.data.notify(frame(typ, times[len(times)-1], m.frameType, m.encode(s, times)))
+	}
+}
+
+// ppi sends PP interval notifications for the beats in each second
+// until stop or the sensor's done channel is closed.
+func (s *Sensor) ppi(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			beats, rr := s.heart.between(last, now)
+			last = now
+			if len(beats) == 0 {
+				continue
+			}
+			s.data.notify(frame(pmd.PPIType, beats[len(beats)-1], byte(pmd.PPIFrameType0), ppiSamples(rr)))
+		}
+	}
+}
+
+// heartRate starts or stops heart rate notifications.
+func (s *Sensor) heartRate(enable bool, stop <-chan struct{}) {
+	if !enable {
+		return
+	}
+	s.mu.Lock()
+	if !s.connected {
+		s.mu.Unlock()
+		return
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.done:
+				return
+			case now := <-ticker.C:
+				_, rr := s.heart.between(last, now)
+				last = now
+				s.hr.notify(heartRateMeasurement(s.heart.rate(), rr))
+			}
+		}
+	}()
+}
+
+// characteristic is a simulated GATT characteristic.
+type characteristic struct {
+	s     *Sensor
+	uuid  bluetooth.UUID
+	read  func() []byte
+	write func([]byte)
+
+	// enable is called when notifications are enabled
+	// or disabled. The stop channel is closed when the
+	// notifications are next changed.
+	enable func(enabled bool, stop <-chan struct{})
+
+	mu       sync.Mutex
+	callback func([]byte)
+	stop     chan struct{}
+}
+
+func (c *characteristic) Read(data []byte) (int, error) {
+	if c.read == nil {
+		return 0, fmt.Errorf("sim: characteristic %s not readable", c.uuid)
+	}
+	return copy(data, c.read()), nil
+}
+
+func (c *characteristic) WriteWithoutResponse(p []byte) (int, error) {
+	if c.write == nil {
+		return 0, fmt.Errorf("sim: characteristic %s not writable", c.uuid)
+	}
+	c.write(bytes.Clone(p))
+	return len(p), nil
+}
+
+func (c *characteristic) EnableNotifications(callback func(buf []byte)) error {
+	c.mu.Lock()
+	c.callback = callback
+	if c.stop != nil {
+		close(c.stop)
+	}
+	c.stop = make(chan struct{})
+	stop := c.stop
+	c.mu.Unlock()
+	if c.enable != nil {
+		c.enable(callback != nil, stop)
+	}
+	return nil
+}
+
+func (c *characteristic) GetMTU() (uint16, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return uint16(c.s.mtu), nil
+}
+
+const (
+	// defaultMTU is the MTU negotiated by Polar sensors.
+	defaultMTU = 232
+	// minMTU is the minimum MTU allowed by Bluetooth LE.
+	minMTU = 23
+	// attHeaderSize is the size of the opcode and
+	// attribute handle that precede the payload of
+	// a notification.
+	attHeaderSize = 3
+)
+
+// notify sends buf to the registered notification callback.
+func (c *characteristic) notify(buf []byte) {
+	c.mu.Lock()
+	callback := c.callback
+	c.mu.Unlock()
+	if callback != nil {
+		callback(buf)
+	}
+}
@@ -0,0 +1,632 @@
+// Copyright ©2025 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim_test
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/kortschak/polar/gatt"
+	"github.com/kortschak/polar/heart"
+	"github.com/kortschak/polar/pmd"
+	"github.com/kortschak/polar/sim"
+)
+
+func TestStreams(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	t.Run("h10", func(t *testing.T) {
+		s, l := listener(t, sim.H10)
+
+		t.Run("hr", func(t *testing.T) {
+			t.Parallel()
+			rates := make(chan heart.Rate, 1)
+			hl, err := heart.NewRateListener(s, func(m heart.Rate, err error) {
+				if err != nil {
+					t.Errorf("failed to decode heart rate: %v", err)
+					return
+				}
+				select {
+				case rates <- m:
+				default:
+				}
+			})
+			if err != nil {
+				t.Fatalf("failed to start heart rate listener: %v", err)
+			}
+			defer hl.Close()
+			select {
+			case <-ctx.Done():
+				t.Fatal("no heart rate notification")
+			case m := <-rates:
+				if m.HR == 0 || !m.Contact {
+					t.Errorf("unexpected heart rate: %+v", m)
+				}
+			}
+		})
+		t.Run("ecg", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler { return pmd.ECGHandler(push) }
+			for i, m := range frames(ctx, t, l, 2, handler, func(_ pmd.ControlPointResponse, buf []byte) (pmd.ECG, error) {
+				var m pmd.ECG
+				err := m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if len(m.Trace) == 0 {
+					t.Errorf("no samples in ecg frame %d", i)
+				}
+			}
+		})
+		t.Run("acc", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler {
+				return pmd.AccHandler{SampleFreq: pmd.AccSampleFreq50, Range: pmd.AccRange8G, Handler: push}
+			}
+			for i, m := range frames(ctx, t, l, 2, handler, func(_ pmd.ControlPointResponse, buf []byte) (pmd.AccFrame, error) {
+				m := pmd.AccFrame{SampleFreq: pmd.AccSampleFreq50}
+				err := m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if len(m.Samples) == 0 {
+					t.Errorf("no samples in acc frame %d", i)
+				}
+			}
+		})
+	})
+
+	t.Run("verity", func(t *testing.T) {
+		_, l := listener(t, sim.VeritySense)
+
+		t.Run("ppg", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler {
+				return pmd.PPGHandler{SampleFreq: pmd.PPGSampleFreq55, Handler: push}
+			}
+			for i, m := range frames(ctx, t, l, 2, handler, func(_ pmd.ControlPointResponse, buf []byte) (pmd.PPGFrame, error) {
+				m := pmd.PPGFrame{SampleFreq: pmd.PPGSampleFreq55}
+				err := m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if len(m.Samples) == 0 {
+					t.Errorf("no samples in ppg frame %d", i)
+				}
+			}
+		})
+		t.Run("ppi", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler { return pmd.PPIHandler(push) }
+			for i, m := range frames(ctx, t, l, 1, handler, func(_ pmd.ControlPointResponse, buf []byte) (pmd.PPIFrame, error) {
+				var m pmd.PPIFrame
+				err := m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if len(m.Samples) == 0 {
+					t.Errorf("no samples in ppi frame %d", i)
+				}
+			}
+		})
+		t.Run("acc", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler {
+				return pmd.AccHandler{SampleFreq: 52, Range: pmd.AccRange8G, Handler: push}
+			}
+			for i, m := range frames(ctx, t, l, 2, handler, func(resp pmd.ControlPointResponse, buf []byte) (pmd.AccFrame, error) {
+				settings, err := resp.Settings()
+				if err != nil {
+					return pmd.AccFrame{}, err
+				}
+				m := pmd.AccFrame{SampleFreq: 52, Factor: pmd.ConversionFactor(settings)}
+				err = m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if m.Factor == 1 {
+					t.Errorf("no conversion factor for acc frame %d", i)
+				}
+				if len(m.Samples) == 0 {
+					t.Errorf("no samples in acc frame %d", i)
+				}
+			}
+		})
+		t.Run("gyro", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler {
+				return pmd.GyroHandler{SampleFreq: pmd.GyroSampleFreq52, Range: pmd.GyroRange250, Handler: push}
+			}
+			for i, m := range frames(ctx, t, l, 2, handler, func(resp pmd.ControlPointResponse, buf []byte) (pmd.GyroFrame, error) {
+				settings, err := resp.Settings()
+				if err != nil {
+					return pmd.GyroFrame{}, err
+				}
+				m := pmd.GyroFrame{SampleFreq: pmd.GyroSampleFreq52, Factor: pmd.ConversionFactor(settings)}
+				err = m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if m.Factor == 1 {
+					t.Errorf("no conversion factor for gyro frame %d", i)
+				}
+				if len(m.Samples) == 0 {
+					t.Errorf("no samples in gyro frame %d", i)
+				}
+			}
+		})
+		t.Run("mag", func(t *testing.T) {
+			t.Parallel()
+			handler := func(push func([]byte)) pmd.Handler {
+				return pmd.MagHandler{SampleFreq: pmd.MagSampleFreq50, Range: pmd.MagRange50G, Handler: push}
+			}
+			for i, m := range frames(ctx, t, l, 2, handler, func(resp pmd.ControlPointResponse, buf []byte) (pmd.MagFrame, error) {
+				settings, err := resp.Settings()
+				if err != nil {
+					return pmd.MagFrame{}, err
+				}
+				m := pmd.MagFrame{SampleFreq: pmd.MagSampleFreq50, Factor: pmd.ConversionFactor(settings)}
+				err = m.UnmarshalBinary(buf)
+				return m, err
+			}) {
+				if m.Factor == 1 {
+					t.Errorf("no conversion factor for mag frame %d", i)
+				}
+				if len(m.Samples) == 0 {
+					t.Errorf("no samples in mag frame %d", i)
+				}
+			}
+		})
+	})
+}
+
+var settingsFragmentsTests = []struct {
+	name    string
+	model   sim.Model
+	measure pmd.MeasureType
+	query   func(*pmd.Listener, context.Context, pmd.MeasureType) ([]pmd.Setting, error)
+}{
+	{
+		name:    "h10 acc",
+		model:   sim.H10,
+		measure: pmd.AccType,
+		query:   (*pmd.Listener).Settings,
+	},
+	{
+		name:    "verity sdk acc",
+		model:   sim.VeritySense,
+		measure: pmd.AccType,
+		query:   (*pmd.Listener).SDKModeSettings,
+	},
+}
+
+func TestSettingsFragments(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, test := range settingsFragmentsTests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := sim.New(test.model, 60)
+			if err != nil {
+				t.Fatalf("failed to create sensor: %v", err)
+			}
+			dev := &notifyCounter{Device: s}
+			l, err := pmd.NewListener(dev)
+			if err != nil {
+				t.Fatalf("failed to create listener: %v", err)
+			}
+			defer l.Close()
+
+			want, err := test.query(l, ctx, test.measure)
+			if err != nil {
+				t.Fatalf("failed to get settings: %v", err)
+			}
+			if n := dev.n.Swap(0); n != 1 {
+				t.Fatalf("unexpected number of notifications at default mtu: got:%d want:1", n)
+			}
+
+			// The minimum MTU leaves 20 bytes for each
+			// notification.
+			s.SetMTU(23)
+			got, err := test.query(l, ctx, test.measure)
+			if err != nil {
+				t.Fatalf("failed to get fragmented settings: %v", err)
+			}
+			if n := dev.n.Load(); n < 2 {
+				t.Errorf("response not fragmented: got %d notifications", n)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected settings:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+var pmdCP = must(bluetooth.ParseUUID(pmd.ControlPointID))
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// notifyCounter is a gatt.Device that counts the notifications sent
+// by the PMD control point.
+type notifyCounter struct {
+	gatt.Device
+	n atomic.Int64
+}
+
+func (d *notifyCounter) Characteristic(srvID, charID bluetooth.UUID) (gatt.Characteristic, error) {
+	c, err := d.Device.Characteristic(srvID, charID)
+	if err != nil || charID != pmdCP {
+		return c, err
+	}
+	return countingCharacteristic{Characteristic: c, n: &d.n}, nil
+}
+
+type countingCharacteristic struct {
+	gatt.Characteristic
+	n *atomic.Int64
+}
+
+func (c countingCharacteristic) EnableNotifications(callback func(buf []byte)) error {
+	if callback == nil {
+		return c.Characteristic.EnableNotifications(nil)
+	}
+	return c.Characteristic.EnableNotifications(func(buf []byte) {
+		c.n.Add(1)
+		callback(buf)
+	})
+}
+
+// listener returns a new simulated sensor of the given model and a
+// pmd.Listener for it. The Listener is closed when the test completes.
+func listener(t *testing.T, model sim.Model) (*sim.Sensor, *pmd.Listener) {
+	t.Helper()
+	s, err := sim.New(model, 60)
+	if err != nil {
+		t.Fatalf("failed to create sensor: %v", err)
+	}
+	l, err := pmd.NewListener(s)
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return s, l
+}
+
+// frames starts the stream with the handler returned by handler and
+// returns the first n notifications decoded by decode. The stream is
+// stopped before frames returns. Notifications are decoded after the
+// stream has started, so decode is called with the sensor's response
+// to the start command.
+func frames[T any](ctx context.Context, t *testing.T, l *pmd.Listener, n int, handler func(push func([]byte)) pmd.Handler, decode func(pmd.ControlPointResponse, []byte) (T, error)) []T {
+	t.Helper()
+	var resp pmd.ControlPointResponse
+	s := pmd.NewStream(n, pmd.DropNewest, func(buf []byte) (T, error) {
+		return decode(resp, buf)
+	})
+	defer s.Close()
+	resp, err := l.SetHandler(ctx, handler(s.Push))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	defer func() {
+		_, err := l.SetHandler(ctx, handler(nil))
+		if err != nil {
+			t.Errorf("failed to stop stream: %v", err)
+		}
+	}()
+	var got []T
+	for v, err := range s.All(ctx) {
+		if err != nil {
+			t.Fatalf("failed to get notification %d: %v", len(got), err)
+		}
+		got = append(got, v)
+		if len(got) == n {
+			break
+		}
+	}
+	return got
+}
+
+func TestMeasurementStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, l := listener(t, sim.H10)
+	_, err := l.SetHandler(ctx, pmd.ECGHandler(func([]byte) {}))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	got, err := l.MeasurementStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to get measurement status: %v", err)
+	}
+	want := pmd.ActiveMeasurements{Online: []pmd.MeasureType{pmd.ECGType}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected status with running stream:\ngot: %+v\nwant:%+v", got, want)
+	}
+
+	_, err = l.SetHandler(ctx, pmd.ECGHandler(nil))
+	if err != nil {
+		t.Fatalf("failed to stop stream: %v", err)
+	}
+	got, err = l.MeasurementStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to get measurement status: %v", err)
+	}
+	if !reflect.DeepEqual(got, pmd.ActiveMeasurements{}) {
+		t.Errorf("unexpected status with no running stream: %+v", got)
+	}
+}
+
+func TestSetSDKMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, l := listener(t, sim.VeritySense)
+	sub, _, err := l.Subscribe(ctx, pmd.PPIHandler(func([]byte) {}))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	err = l.SetSDKMode(ctx, true)
+	if err == nil {
+		t.Error("expected error setting sdk mode with running stream")
+	}
+	enabled, err := l.SDKMode(ctx)
+	if err != nil {
+		t.Fatalf("failed to get sdk mode: %v", err)
+	}
+	if enabled {
+		t.Error("sdk mode changed with running stream")
+	}
+	got, err := l.MeasurementStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to get measurement status: %v", err)
+	}
+	want := pmd.ActiveMeasurements{Online: []pmd.MeasureType{pmd.PPIType}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected status after refused mode change:\ngot: %+v\nwant:%+v", got, want)
+	}
+
+	err = sub.Unsubscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+	for i := range 2 {
+		err = l.SetSDKMode(ctx, true)
+		if err != nil {
+			t.Fatalf("failed to set sdk mode %d: %v", i, err)
+		}
+	}
+	enabled, err = l.SDKMode(ctx)
+	if err != nil {
+		t.Fatalf("failed to get sdk mode: %v", err)
+	}
+	if !enabled {
+		t.Error("sdk mode not enabled")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, err := sim.New(sim.VeritySense, 60)
+	if err != nil {
+		t.Fatalf("failed to create sensor: %v", err)
+	}
+	dev := &notifyCounter{Device: s}
+	l, err := pmd.NewListener(dev)
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	defer l.Close()
+
+	running := func(want ...pmd.MeasureType) {
+		t.Helper()
+		got, err := l.MeasurementStatus(ctx)
+		if err != nil {
+			t.Fatalf("failed to get measurement status: %v", err)
+		}
+		if !reflect.DeepEqual(got.Online, want) {
+			t.Errorf("unexpected running streams: got:%v want:%v", got.Online, want)
+		}
+		// Discount the status query.
+		dev.n.Add(-1)
+	}
+	notified := func(c <-chan struct{}, who string) {
+		t.Helper()
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no notification for %s", who)
+		case <-c:
+		}
+	}
+	handler := func(c chan struct{}) pmd.Handler {
+		return pmd.AccHandler{SampleFreq: 52, Range: pmd.AccRange8G, Handler: func([]byte) {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}}
+	}
+
+	// The first subscription starts the stream.
+	c1 := make(chan struct{}, 1)
+	sub1, resp1, err := l.Subscribe(ctx, handler(c1))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if n := dev.n.Swap(0); n != 1 {
+		t.Errorf("unexpected number of commands for first subscription: got:%d want:1", n)
+	}
+	running(pmd.AccType)
+	notified(c1, "first subscription")
+
+	// Later subscriptions share the stream.
+	c2 := make(chan struct{}, 1)
+	sub2, resp2, err := l.Subscribe(ctx, handler(c2))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if n := dev.n.Swap(0); n != 0 {
+		t.Errorf("unexpected number of commands for second subscription: got:%d want:0", n)
+	}
+	if !reflect.DeepEqual(resp2, resp1) {
+		t.Errorf("unexpected response for second subscription:\ngot: %+v\nwant:%+v", resp2, resp1)
+	}
+	notified(c2, "second subscription")
+
+	// Subscriptions and handlers with different
+	// settings are rejected.
+	mismatched := pmd.AccHandler{SampleFreq: 26, Range: pmd.AccRange8G, Handler: func([]byte) {}}
+	_, _, err = l.Subscribe(ctx, mismatched)
+	if err == nil {
+		t.Error("expected error for subscription with mismatched settings")
+	}
+	_, err = l.SetHandler(ctx, mismatched)
+	if err == nil {
+		t.Error("expected error for handler with mismatched settings")
+	}
+	if n := dev.n.Swap(0); n != 0 {
+		t.Errorf("unexpected number of commands for mismatched settings: got:%d want:0", n)
+	}
+
+	// A handler shares the stream and removing it
+	// does not stop the stream.
+	ch := make(chan struct{}, 1)
+	resp, err := l.SetHandler(ctx, handler(ch))
+	if err != nil {
+		t.Fatalf("failed to set handler: %v", err)
+	}
+	if !reflect.DeepEqual(resp, resp1) {
+		t.Errorf("unexpected response for handler:\ngot: %+v\nwant:%+v", resp, resp1)
+	}
+	notified(ch, "handler")
+	_, err = l.SetHandler(ctx, pmd.AccHandler{})
+	if err != nil {
+		t.Fatalf("failed to remove handler: %v", err)
+	}
+	if n := dev.n.Swap(0); n != 0 {
+		t.Errorf("unexpected number of commands for shared handler: got:%d want:0", n)
+	}
+	running(pmd.AccType)
+	select {
+	case <-c1:
+	default:
+	}
+	notified(c1, "first subscription after handler removal")
+
+	// The stream is stopped by the last unsubscription.
+	err = sub1.Unsubscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+	if n := dev.n.Swap(0); n != 0 {
+		t.Errorf("unexpected number of commands for first unsubscription: got:%d want:0", n)
+	}
+	running(pmd.AccType)
+	err = sub2.Unsubscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+	if n := dev.n.Swap(0); n != 1 {
+		t.Errorf("unexpected number of commands for last unsubscription: got:%d want:1", n)
+	}
+	running()
+	err = sub2.Unsubscribe(ctx)
+	if err != nil {
+		t.Errorf("unexpected error repeating unsubscription: %v", err)
+	}
+}
+
+func TestOfflineTriggers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("verity", func(t *testing.T) {
+		_, l := listener(t, sim.VeritySense)
+
+		got, err := l.OfflineTriggers(ctx)
+		if err != nil {
+			t.Fatalf("failed to get initial triggers: %v", err)
+		}
+		want := pmd.TriggerStatus{
+			Mode: pmd.TriggerDisabled,
+			Triggers: []pmd.Trigger{
+				{Measure: pmd.PPGType},
+				{Measure: pmd.AccType},
+				{Measure: pmd.PPIType},
+				{Measure: pmd.GyroType},
+				{Measure: pmd.MagnetometerType},
+			},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected initial triggers:\ngot: %+v\nwant:%+v", got, want)
+		}
+
+		accSettings := []pmd.Setting{
+			pmd.Uint16{Type: pmd.SampleRateSetting, Val: []uint16{52}},
+			pmd.Uint16{Type: pmd.ResolutionSetting, Val: []uint16{16}},
+			pmd.Uint16{Type: pmd.RangeUnitSetting, Val: []uint16{8}},
+		}
+		err = l.SetOfflineTriggerMode(ctx, pmd.TriggerSystemStart)
+		if err != nil {
+			t.Fatalf("failed to set trigger mode: %v", err)
+		}
+		err = l.SetOfflineTrigger(ctx, pmd.AccType, true, accSettings...)
+		if err != nil {
+			t.Fatalf("failed to enable acc trigger: %v", err)
+		}
+		err = l.SetOfflineTrigger(ctx, pmd.PPIType, true)
+		if err != nil {
+			t.Fatalf("failed to enable ppi trigger: %v", err)
+		}
+		got, err = l.OfflineTriggers(ctx)
+		if err != nil {
+			t.Fatalf("failed to get enabled triggers: %v", err)
+		}
+		want.Mode = pmd.TriggerSystemStart
+		want.Triggers[1] = pmd.Trigger{Measure: pmd.AccType, Enabled: true, Settings: accSettings}
+		want.Triggers[2] = pmd.Trigger{Measure: pmd.PPIType, Enabled: true}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected enabled triggers:\ngot: %+v\nwant:%+v", got, want)
+		}
+
+		err = l.SetOfflineTrigger(ctx, pmd.AccType, false)
+		if err != nil {
+			t.Fatalf("failed to disable acc trigger: %v", err)
+		}
+		got, err = l.OfflineTriggers(ctx)
+		if err != nil {
+			t.Fatalf("failed to get triggers after disabling: %v", err)
+		}
+		want.Triggers[1] = pmd.Trigger{Measure: pmd.AccType}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected triggers after disabling:\ngot: %+v\nwant:%+v", got, want)
+		}
+
+		err = l.SetOfflineTriggerMode(ctx, pmd.TriggerExerciseStart+1)
+		if err == nil {
+			t.Error("expected error for invalid trigger mode")
+		}
+		err = l.SetOfflineTrigger(ctx, pmd.ECGType, true)
+		if err == nil {
+			t.Error("expected error for unsupported measurement type")
+		}
+	})
+
+	t.Run("h10", func(t *testing.T) {
+		_, l := listener(t, sim.H10)
+		_, err := l.OfflineTriggers(ctx)
+		if err == nil {
+			t.Error("expected error for sensor without offline recording")
+		}
+	})
+}